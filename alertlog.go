@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// alertEvent is an ORA error found in an alert log.
+type alertEvent struct {
+	Time     time.Time `json:"time"`
+	Database string    `json:"database"`
+	Instance string    `json:"instance"`
+	Code     string    `json:"code"`
+	Message  string    `json:"message"`
+	Ignored  bool      `json:"ignored,omitempty"`
+}
+
+// alertRecord is a line of the -alertlog.state file: the position read up to in an alert
+// log with the last timestamp line before it, or an event found at offset.
+type alertRecord struct {
+	File   string      `json:"file"`
+	Offset int64       `json:"offset"`
+	Time   *time.Time  `json:"time,omitempty"`
+	Event  *alertEvent `json:"event,omitempty"`
+}
+
+// alertPosition is where reading an alert log continues.
+type alertPosition struct {
+	offset int64
+	time   time.Time
+}
+
+// alertStore keeps the read positions and the events of the last -alertlog.retention in
+// a JSON lines file, so a restart neither misses nor counts an error twice.
+type alertStore struct {
+	path      string
+	lok       sync.Mutex
+	positions map[string]alertPosition
+	events    []alertRecord
+	seen      map[string]bool
+	appended  int // records appended since the last compact
+}
+
+var (
+	oraRe = regexp.MustCompile(`ORA-0*(\d+)`)
+	// alertTimeFormats are the timestamp lines of 12.2+ and of older alert logs
+	alertTimeFormats = []string{time.RFC3339Nano, "Mon Jan _2 15:04:05 2006"}
+)
+
+// oraCode returns the code of an ORA error as ORA-NNNNN, e.g. ORA-03136 for ORA-3136.
+func oraCode(s string) string {
+	m := oraRe.FindStringSubmatch(s)
+	if m == nil {
+		return s
+	}
+	n, _ := strconv.Atoi(m[1])
+	return fmt.Sprintf("ORA-%05d", n)
+}
+
+func (r alertRecord) key() string {
+	return fmt.Sprintf("%s\x00%d\x00%s\x00%s", r.File, r.Offset, r.Event.Code, r.Event.Time.Format(time.RFC3339Nano))
+}
+
+// loadAlertStore reads the state file at path and rewrites it without the events older
+// than -alertlog.retention. A missing file is an empty state.
+func loadAlertStore(path string) (*alertStore, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(pwd, path)
+	}
+	s := &alertStore{path: path, positions: make(map[string]alertPosition), seen: make(map[string]bool)}
+	fh, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	oldest := time.Now().Add(-*alertRetain)
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		var r alertRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			log.Warnln(path+":", err)
+			continue
+		}
+		if r.Event == nil {
+			s.positions[r.File] = r.position()
+		} else if r.Event.Time.After(oldest) && !s.seen[r.key()] {
+			s.seen[r.key()] = true
+			s.events = append(s.events, r)
+		}
+	}
+	fh.Close()
+	if err := scanner.Err(); err != nil {
+		return s, err
+	}
+	return s, s.compact()
+}
+
+// compact rewrites the state file with the current positions and events.
+func (s *alertStore) compact() error {
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), "."+filepath.Base(s.path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	var records []alertRecord
+	for file, pos := range s.positions {
+		records = append(records, positionRecord(file, pos))
+	}
+	records = append(records, s.events...)
+	err = writeRecords(tmp, records)
+	s.appended = 0
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func writeRecords(w io.Writer, records []alertRecord) error {
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// append adds the events and the new position of a file to the state file.
+func (s *alertStore) append(records []alertRecord) error {
+	fh, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	err = writeRecords(fh, records)
+	s.appended += len(records)
+	if cerr := fh.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// read parses the alert log at path from the last position, until the end or ctx is done,
+// and stores the new events of conf.
+func (s *alertStore) read(ctx context.Context, conf *Config, alert Alert) error {
+	s.lok.Lock()
+	defer s.lok.Unlock()
+
+	fh, err := os.Open(alert.File)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	fi, err := fh.Stat()
+	if err != nil {
+		return err
+	}
+	pos := s.positions[alert.File]
+	if pos.offset > fi.Size() {
+		// rotated or truncated
+		pos = alertPosition{}
+	}
+	offset := pos.offset
+	if _, err := fh.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	ignore := make(map[string]bool)
+	for _, code := range alert.Ignoreora {
+		ignore[oraCode(code)] = true
+	}
+	var records []alertRecord
+	ts := pos.time
+	if ts.IsZero() {
+		ts = fi.ModTime()
+	}
+	reader := bufio.NewReader(fh)
+	for lines := 0; ; lines++ {
+		if lines%1000 == 0 && ctx.Err() != nil {
+			// continued on the next scrape
+			break
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// a partly written last line is read again on the next scrape
+			break
+		}
+		start := offset
+		offset += int64(len(line))
+		line = strings.TrimSpace(line)
+		if t, ok := parseAlertTime(line); ok {
+			ts = t
+			continue
+		}
+		codes := make(map[string]bool)
+		for _, m := range oraRe.FindAllString(line, -1) {
+			code := oraCode(m)
+			if codes[code] {
+				continue
+			}
+			codes[code] = true
+			r := alertRecord{File: alert.File, Offset: start, Event: &alertEvent{
+				Time: ts, Database: conf.Database, Instance: conf.Instance,
+				Code: code, Message: line, Ignored: ignore[code],
+			}}
+			if !s.seen[r.key()] {
+				s.seen[r.key()] = true
+				records = append(records, r)
+			}
+		}
+	}
+	if offset == s.positions[alert.File].offset && len(records) == 0 {
+		return nil
+	}
+	s.positions[alert.File] = alertPosition{offset, ts}
+	s.events = append(s.events, records...)
+	s.expire()
+	if s.appended > len(s.events)+len(s.positions)+1000 {
+		return s.compact()
+	}
+	return s.append(append(records, positionRecord(alert.File, s.positions[alert.File])))
+}
+
+func positionRecord(file string, pos alertPosition) alertRecord {
+	t := pos.time
+	return alertRecord{File: file, Offset: pos.offset, Time: &t}
+}
+
+func (r alertRecord) position() alertPosition {
+	pos := alertPosition{offset: r.Offset}
+	if r.Time != nil {
+		pos.time = *r.Time
+	}
+	return pos
+}
+
+// expire drops the events older than -alertlog.retention.
+func (s *alertStore) expire() {
+	oldest := time.Now().Add(-*alertRetain)
+	events := s.events[:0]
+	for _, r := range s.events {
+		if r.Event.Time.After(oldest) {
+			events = append(events, r)
+		} else {
+			delete(s.seen, r.key())
+		}
+	}
+	s.events = events
+}
+
+// parseAlertTime returns the time of a timestamp line of an alert log.
+func parseAlertTime(line string) (time.Time, bool) {
+	for _, format := range alertTimeFormats {
+		if t, err := time.ParseInLocation(format, line, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// recent returns the events since t of the target name (database or instance, all if
+// empty), the newest first.
+func (s *alertStore) recent(name string, t time.Time) []alertEvent {
+	s.lok.Lock()
+	defer s.lok.Unlock()
+	var events []alertEvent
+	for _, r := range s.events {
+		if r.Event.Time.After(t) && (name == "" || r.Event.Database == name || r.Event.Instance == name) {
+			events = append(events, *r.Event)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.After(events[j].Time) })
+	return events
+}
+
+// ScrapeAlertlog reads the new lines of the alert logs of conn and exports the ORA errors
+// of the last -alertlog.window per code.
+func (e *Exporter) ScrapeAlertlog(ctx context.Context, conn *Config) error {
+	if len(conn.Alertlog) == 0 || e.alerts == nil {
+		return nil
+	}
+	var err error
+	for _, alert := range conn.Alertlog {
+		if rerr := e.alerts.read(ctx, conn, alert); rerr != nil {
+			err = rerr
+			continue
+		}
+		if fi, serr := os.Stat(alert.File); serr == nil {
+			e.alertdate.WithLabelValues(conn.Database, conn.Instance).Set(float64(fi.ModTime().Unix()))
+		}
+	}
+
+	type count struct {
+		n       int
+		message string
+		ignored bool
+	}
+	counts := make(map[string]*count)
+	// the newest first, the description is the latest message of a code
+	for _, ev := range e.alerts.recent("", time.Now().Add(-*alertWindow)) {
+		if ev.Database != conn.Database || ev.Instance != conn.Instance {
+			continue
+		}
+		c := counts[ev.Code]
+		if c == nil {
+			c = &count{message: ev.Message, ignored: ev.Ignored}
+			counts[ev.Code] = c
+		}
+		c.n++
+	}
+	for code, c := range counts {
+		e.alertlog.WithLabelValues(conn.Database, conn.Instance, code, c.message, strconv.FormatBool(c.ignored)).Set(float64(c.n))
+	}
+	return err
+}
+
+// AlertsHandler writes the ORA errors found in the alert logs as JSON, the newest first:
+// target=X of one database or instance, since=1h (default -alertlog.window), limit=100.
+func (e *Exporter) AlertsHandler(w http.ResponseWriter, r *http.Request) {
+	if e.alerts == nil {
+		http.Error(w, "no alert log state", http.StatusServiceUnavailable)
+		return
+	}
+	since := *alertWindow
+	if v := r.URL.Query().Get("since"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			http.Error(w, "since: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		since = d
+	}
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "bad limit "+v, http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	events := e.alerts.recent(r.URL.Query().Get("target"), time.Now().Add(-since))
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	if events == nil {
+		events = []alertEvent{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(events)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Aq enables the Advanced Queuing collector of a connection, AQ backlogs break integrations
+// without any error in the database.
+type Aq struct {
+	// Queues are owner.queue LIKE patterns of the exported queues, all queues of the
+	// users not maintained by Oracle if empty (12c and later)
+	Queues []string `yaml:"queues,omitempty"`
+	// QueueTables are owner.table queue tables read for the age of the oldest message
+	QueueTables []string `yaml:"queue_tables,omitempty"`
+}
+
+// queueTableRe matches a queue table qualified with its owner, it is part of the SQL.
+var queueTableRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_$#]*\.[A-Za-z][A-Za-z0-9_$#]*$`)
+
+// aqViews are the views read by ScrapeAq besides the queue tables.
+var aqViews = []string{"dba_queues", "gv$aq", "dba_users"}
+
+// validate checks the queue tables of a.
+func (a *Aq) validate() error {
+	for _, t := range a.QueueTables {
+		if !queueTableRe.MatchString(t) {
+			return fmt.Errorf("queue_tables: %q is not owner.table", t)
+		}
+	}
+	return nil
+}
+
+// ScrapeAq collects the waiting, ready and expired messages of the queues of conn summed
+// over the instances, and the age of the oldest ready or waiting message of the queues
+// in the queue tables.
+func (e *Exporter) ScrapeAq(ctx context.Context, conn *Config) error {
+	if conn.db == nil || conn.Aq == nil {
+		return nil
+	}
+	filter := `q.owner not in (select username from dba_users where oracle_maintained = 'Y')`
+	var args []interface{}
+	if len(conn.Aq.Queues) > 0 {
+		conds := make([]string, len(conn.Aq.Queues))
+		for i, q := range conn.Aq.Queues {
+			conds[i] = fmt.Sprintf("q.owner || '.' || q.name like :%d", i+1)
+			args = append(args, strings.ToUpper(q))
+		}
+		filter = "(" + strings.Join(conds, " or ") + ")"
+	}
+	rows, err := conn.db.QueryContext(ctx, `select q.owner, q.name, sum(a.waiting), sum(a.ready), sum(a.expired)
+                                 from dba_queues q join gv$aq a on a.qid = q.qid
+                                 where q.queue_type = 'NORMAL_QUEUE' and `+filter+`
+                                 group by q.owner, q.name`, args...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var owner, name string
+		var waiting, ready, expired float64
+		if err = rows.Scan(&owner, &name, &waiting, &ready, &expired); err != nil {
+			break
+		}
+		e.aqMessages.WithLabelValues(conn.Database, conn.Instance, owner, name, "waiting").Set(waiting)
+		e.aqMessages.WithLabelValues(conn.Database, conn.Instance, owner, name, "ready").Set(ready)
+		e.aqMessages.WithLabelValues(conn.Database, conn.Instance, owner, name, "expired").Set(expired)
+	}
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	for _, table := range conn.Aq.QueueTables {
+		owner := strings.ToUpper(strings.SplitN(table, ".", 2)[0])
+		// state 0 is ready, 1 waiting for its delay
+		rows, err := conn.db.QueryContext(ctx, `select q_name, (sysdate - cast(min(enq_time) as date)) * 86400
+                                     from `+table+`
+                                     where state in (0, 1)
+                                     group by q_name`)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var name string
+			var age float64
+			if err = rows.Scan(&name, &age); err != nil {
+				break
+			}
+			e.aqAge.WithLabelValues(conn.Database, conn.Instance, owner, name).Set(age)
+		}
+		rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
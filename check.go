@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// sqlLiteralRe matches the string literals, quoted identifiers and comments of a query.
+var sqlLiteralRe = regexp.MustCompile(`(?s)'(?:[^']|'')*'|"[^"]*"|--[^\n]*|/\*.*?\*/`)
+
+// bindRe matches bind placeholders like :1 or :name, custom queries are run without binds.
+var bindRe = regexp.MustCompile(`:[a-zA-Z0-9_]+`)
+
+// reservedLabels are added to every custom metric by the exporter.
+var reservedLabels = []string{"metric", "database", "dbinstance", "rownum"}
+
+// sqlProblems returns the problems of the SQL text of a custom query.
+func sqlProblems(sql string) []string {
+	var problems []string
+	if strings.Contains(sql, "${") {
+		problems = append(problems, "contains ${ which is not expanded in queries")
+	}
+	code := sqlLiteralRe.ReplaceAllString(sql, "''")
+	if strings.Count(code, "'")%2 != 0 {
+		problems = append(problems, "unterminated string literal")
+	}
+	if m := bindRe.FindString(code); m != "" {
+		problems = append(problems, fmt.Sprintf("bind placeholder %s, custom queries are run without binds", m))
+	}
+	if strings.HasSuffix(strings.TrimSpace(code), ";") {
+		problems = append(problems, "ends with ; (ORA-00911)")
+	}
+	return problems
+}
+
+// configProblems checks everything in c which can be checked without a database and
+// returns all problems found, validateConfig stops at the first one.
+func configProblems(c Configs) []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	if err := validateConfig(c); err != nil {
+		add("%v", err)
+	}
+	if c.TargetsFile != "" {
+		if _, _, err := readTargets(expandEnv(c.TargetsFile), c.Ldap, c.Defaults); err != nil {
+			add("targets_file: %v", err)
+		}
+	}
+
+	for i, conf := range c.Cfgs {
+		where := fmt.Sprintf("connection %d (%s)", i+1, conf.Database)
+		for j, stmt := range conf.InitSQL {
+			for _, p := range sqlProblems(stmt) {
+				add("%s init_sql %d: %s", where, j+1, p)
+			}
+		}
+		names := make(map[string]bool)
+		for _, query := range conf.Queries {
+			q := fmt.Sprintf("%s query %s", where, query.Name)
+			if names[query.Name] {
+				add("%s: duplicate query name in this connection", q)
+			}
+			names[query.Name] = true
+			for _, p := range sqlProblems(query.Sql) {
+				add("%s: %s", q, p)
+			}
+
+			seen := make(map[string]string)
+			for _, label := range query.Labels {
+				name := cleanName(label)
+				if !labelNameRe.MatchString(name) {
+					add("%s: label %q is not a valid label name", q, label)
+				}
+				for _, r := range reservedLabels {
+					if name == r {
+						add("%s: label %q collides with the label %s added by the exporter", q, label, r)
+					}
+				}
+				if query.Rac && name == "inst_id" {
+					add("%s: label %q collides with the label inst_id added by rac", q, label)
+				}
+				if _, ok := conf.Labels[name]; ok {
+					add("%s: label %q hides the label of the connection of the same name", q, label)
+				}
+				if prev, ok := seen[name]; ok {
+					add("%s: labels %q and %q are both exported as %s", q, prev, label, name)
+				}
+				seen[name] = label
+			}
+			metrics := make(map[string]bool)
+			for _, metric := range query.Metrics {
+				if metrics[cleanName(metric)] {
+					add("%s: metric column %q listed twice", q, metric)
+				}
+				metrics[cleanName(metric)] = true
+			}
+			for _, derived := range query.Derived {
+				if derived.Name == "" || derived.Expr == "" {
+					add("%s: derived metric needs name and expr", q)
+				} else if metrics[cleanName(derived.Name)] {
+					add("%s: derived metric %q collides with a metric column", q, derived.Name)
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// checkConfig validates the config file without connecting to any database and writes a
+// report to w, it returns false if there are problems.
+func checkConfig(w io.Writer, path string) bool {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return false
+	}
+	var c Configs
+	if err = yaml.UnmarshalStrict(content, &c); err == nil {
+		err = includeConfigs(&c, filepath.Dir(path))
+	}
+	if err == nil {
+		// the labels of the defaults may collide as well
+		applyDefaults(&c)
+	}
+	if err != nil {
+		fmt.Fprintf(w, "%s: %v\n", path, err)
+		return false
+	}
+	problems := configProblems(c)
+	for _, p := range problems {
+		fmt.Fprintf(w, "%s: %s\n", path, p)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(w, "%s: %d problems\n", path, len(problems))
+		return false
+	}
+	queries := 0
+	for _, conf := range c.Cfgs {
+		queries += len(conf.Queries)
+	}
+	fmt.Fprintf(w, "%s: ok, %d connections, %d custom queries\n", path, len(c.Cfgs), queries)
+	return true
+}
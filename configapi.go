@@ -0,0 +1,410 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"go/parser"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// maxConfigSize limits the body of POST /config.
+const maxConfigSize = 4 << 20
+
+const redacted = "REDACTED"
+
+// tnsPasswordRe matches the password of a user/password@tnsname connection.
+var tnsPasswordRe = regexp.MustCompile(`^([^/@:]+)/[^@]*@`)
+
+// urlPasswordRe matches the password of an URL which does not parse, e.g. a srv_targets
+// template with {port}.
+var urlPasswordRe = regexp.MustCompile(`://([^/@:]*):[^@]*@`)
+
+// redactConnection hides the password of a connection string.
+func redactConnection(conn string) string {
+	if strings.Contains(conn, "://") {
+		u, err := url.Parse(conn)
+		if err != nil {
+			return urlPasswordRe.ReplaceAllString(conn, "://${1}:"+redacted+"@")
+		}
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+		return u.String()
+	}
+	return tnsPasswordRe.ReplaceAllString(conn, "${1}/"+redacted+"@")
+}
+
+// redactedConfig returns a copy of c without passwords. Unless effective, the connections
+// of include files, targets files and SRV records are left out, as they are not part of
+// the config file.
+func redactedConfig(c Configs, effective bool) Configs {
+	r := Configs{Include: c.Include, TargetsFile: c.TargetsFile}
+	if c.Defaults != nil {
+		d := *c.Defaults
+		d.Options = redactOptions(d.Options)
+		r.Defaults = &d
+	}
+	for _, srv := range c.SrvTargets {
+		if srv.included && !effective {
+			continue
+		}
+		srv.Template.Connection = redactConnection(srv.Template.Connection)
+		srv.Template.Options = redactOptions(srv.Template.Options)
+		r.SrvTargets = append(r.SrvTargets, srv)
+	}
+	if c.Ldap != nil {
+		ldap := *c.Ldap
+		if ldap.BindPassword != "" {
+			ldap.BindPassword = redacted
+		}
+		r.Ldap = &ldap
+	}
+	for _, conf := range c.Cfgs {
+		if (conf.discovered || conf.included) && !effective {
+			continue
+		}
+		conf.db = nil
+		conf.ldap = r.Ldap
+		conf.password = ""
+		conf.Connection = redactConnection(conf.Connection)
+		conf.Options = redactOptions(conf.Options)
+		r.Cfgs = append(r.Cfgs, conf)
+	}
+	return r
+}
+
+// redactOptions returns a copy of the driver options without password values.
+func redactOptions(options map[string]string) map[string]string {
+	if len(options) == 0 {
+		return options
+	}
+	r := make(map[string]string, len(options))
+	for name, value := range options {
+		if strings.Contains(strings.ToUpper(name), "PASSWORD") {
+			value = redacted
+		}
+		r[name] = value
+	}
+	return r
+}
+
+// writeEffectiveConfig writes the running config as JSON, with the connections of include
+// files, targets files and SRV records and without passwords.
+func writeEffectiveConfig(w http.ResponseWriter) {
+	cfgLok.Lock()
+	c := redactedConfig(config, true)
+	cfgLok.Unlock()
+	out, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(out)
+}
+
+// validateCollectors checks the collector names of c.
+func validateCollectors(c *Collectors) error {
+	if c == nil {
+		return nil
+	}
+	for _, name := range append(c.Include, c.Exclude...) {
+		if !knownCollector(name) {
+			return fmt.Errorf("unknown collector %q", name)
+		}
+	}
+	return nil
+}
+
+// knownCollector reports whether name is one of collectorNames.
+func knownCollector(name string) bool {
+	for _, c := range collectorNames {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateConfig checks a config before it replaces the running one.
+func validateConfig(c Configs) error {
+	if len(c.Cfgs) == 0 && c.TargetsFile == "" && len(c.SrvTargets) == 0 && len(c.Include) == 0 {
+		return fmt.Errorf("no connections")
+	}
+	for _, srv := range c.SrvTargets {
+		if srv.Name == "" || !strings.Contains(srv.Template.Connection, "{host}") {
+			return fmt.Errorf("srv_targets: name and a template connection with {host} required")
+		}
+	}
+	if d := c.Defaults; d != nil {
+		if d.Timeout < 0 {
+			return fmt.Errorf("defaults: negative timeout")
+		}
+		if err := validateCollectors(d.Collectors); err != nil {
+			return fmt.Errorf("defaults: %v", err)
+		}
+		for name := range d.Labels {
+			if !labelNameRe.MatchString(name) {
+				return fmt.Errorf("defaults: invalid label name %q", name)
+			}
+		}
+	}
+	labels := make(map[string]string)
+	for i, conf := range c.Cfgs {
+		if conf.Connection == "" && conf.Database == "" {
+			return fmt.Errorf("connection %d: connection or database required", i+1)
+		}
+		if conf.Timeout < 0 {
+			return fmt.Errorf("connection %d: negative timeout", i+1)
+		}
+		if r := strings.ToLower(conf.Role); r != "" && r != "sysdba" && r != "sysoper" {
+			return fmt.Errorf("connection %d: role must be sysdba or sysoper", i+1)
+		}
+		if err := validateCollectors(conf.Collectors); err != nil {
+			return fmt.Errorf("connection %d: %v", i+1, err)
+		}
+		if h := conf.Heavy; h != nil {
+			if err := validateCollectors(&Collectors{Include: h.Collectors}); err != nil {
+				return fmt.Errorf("connection %d: heavy: %v", i+1, err)
+			}
+			if h.Interval < 0 || h.Timeout < 0 {
+				return fmt.Errorf("connection %d: heavy: negative interval or timeout", i+1)
+			}
+		}
+		if conf.Oem != nil {
+			for _, m := range conf.Oem.Metrics {
+				if m.Metric == "" || m.Column == "" {
+					return fmt.Errorf("connection %d: oem metrics need metric and column", i+1)
+				}
+			}
+		}
+		if conf.Aq != nil {
+			if err := conf.Aq.validate(); err != nil {
+				return fmt.Errorf("connection %d: aq: %v", i+1, err)
+			}
+		}
+		if a := conf.CyberArk; a != nil && (a.URL == "" || a.AppID == "" || a.Object == "") {
+			return fmt.Errorf("connection %d: cyberark needs url, app_id and object", i+1)
+		}
+		for _, name := range conf.Unset {
+			if !unsetRe.MatchString(name) {
+				return fmt.Errorf("connection %d: unset %q, want timeout, collectors, options, labels, options.<name> or labels.<name>", i+1, name)
+			}
+		}
+		if conf.Namespace != "" {
+			if err := validNamespace(conf.Namespace); err != nil {
+				return fmt.Errorf("connection %d: %v", i+1, err)
+			}
+		}
+		if conf.Health != "" {
+			if _, err := parser.ParseExpr(conf.Health); err != nil {
+				return fmt.Errorf("connection %d: health: %v", i+1, err)
+			}
+		}
+		for name := range conf.Labels {
+			if !labelNameRe.MatchString(name) {
+				return fmt.Errorf("connection %d: invalid label name %q", i+1, name)
+			}
+		}
+		for _, query := range conf.Queries {
+			if query.Name == "" || query.Sql == "" || query.Help == "" {
+				return fmt.Errorf("connection %d: query needs name, sql and help", i+1)
+			}
+			if len(query.Metrics) == 0 && len(query.Derived) == 0 {
+				return fmt.Errorf("query %s: no metrics", query.Name)
+			}
+			if query.ValueType != "" && query.ValueType != "timestamp" {
+				return fmt.Errorf("query %s: unknown value_type %q", query.Name, query.ValueType)
+			}
+			// queries of the same name share one metric and must have the same labels
+			l := strings.Join(query.Labels, ",")
+			if query.Rac {
+				for _, label := range query.Labels {
+					if cleanName(label) == "inst_id" {
+						return fmt.Errorf("query %s: label %q collides with the label inst_id added by rac", query.Name, label)
+					}
+				}
+				l += ",inst_id"
+			}
+			if prev, ok := labels[query.Name]; ok && prev != l {
+				return fmt.Errorf("query %s: labels differ from another query of the same name", query.Name)
+			}
+			labels[query.Name] = l
+		}
+	}
+	return nil
+}
+
+// authorized checks admin requests changing the exporter or profiling it: they need the
+// bearer token of -web.admin-token, or the basic auth of one of the -web.admin-users.
+// The other basic_auth_users of the web config may only read, e.g. scrape the metrics.
+func authorized(r *http.Request) bool {
+	if *adminToken != "" {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(*adminToken)) == 1 {
+			return true
+		}
+	}
+	if !basicAuthEnabled {
+		return false
+	}
+	// the password was checked by basicAuth before
+	user, _, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	for _, admin := range strings.Split(*adminUsers, ",") {
+		if admin = strings.TrimSpace(admin); admin != "" && admin == user {
+			return true
+		}
+	}
+	return false
+}
+
+// adminHandler restricts h to authorized POST requests.
+func adminHandler(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		if !authorized(r) {
+			unauthorized(w)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// unauthorized answers a request failing authorized, forbidden if neither a token nor
+// admin users are configured or the basic auth user is no admin.
+func unauthorized(w http.ResponseWriter) {
+	if *adminToken == "" && (!basicAuthEnabled || *adminUsers == "") {
+		http.Error(w, "admin routes are disabled, set -web.admin-token or -web.admin-users", http.StatusForbidden)
+		return
+	}
+	if basicAuthEnabled {
+		http.Error(w, "forbidden, not one of -web.admin-users", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("WWW-Authenticate", `Bearer realm="prometheus_oracle_exporter"`)
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+}
+
+// authorizedHandler restricts h to authorized requests of any method, e.g. the profiler.
+func authorizedHandler(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			unauthorized(w)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// writeConfig replaces the config file by content, via a temporary file and rename.
+func writeConfig(content []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(*configFile), "."+filepath.Base(*configFile)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	mode := os.FileMode(0600)
+	if fi, err := os.Stat(*configFile); err == nil {
+		mode = fi.Mode()
+	}
+	_, err = tmp.Write(content)
+	if err == nil {
+		err = tmp.Chmod(mode)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), *configFile)
+}
+
+// ConfigHandler exports the running config as YAML without passwords (GET), or validates
+// and replaces the config file and reloads it (POST, see authorized), so the
+// targets can be reconciled remotely, e.g. by a GitOps controller.
+func (e *Exporter) ConfigHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		// the file as written, without the defaults applied, unless effective
+		cfgLok.Lock()
+		var c Configs
+		if r.URL.Query().Get("effective") == "1" {
+			c = redactedConfig(config, true)
+		} else {
+			c = redactedConfig(fileConfig, false)
+		}
+		cfgLok.Unlock()
+		out, err := yaml.Marshal(c)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(out)
+
+	case http.MethodPost:
+		if !authorized(r) {
+			unauthorized(w)
+			return
+		}
+		content, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxConfigSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var c Configs
+		if err = yaml.UnmarshalStrict(content, &c); err == nil {
+			// the config as it would run, with its include files and passwords
+			c, err = parseConfig(content, filepath.Dir(*configFile))
+		}
+		if err == nil {
+			err = validateConfig(c)
+		}
+		if err != nil {
+			http.Error(w, "invalid config: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		previous, err := ioutil.ReadFile(*configFile)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := writeConfig(content); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := loadConfig(); err != nil {
+			// e.g. an include file changed meanwhile, restore the file of the running config
+			if rerr := writeConfig(previous); rerr != nil {
+				log.Errorln("config: restoring", *configFile, "failed:", rerr)
+			}
+			http.Error(w, "config not loaded, the previous config is kept: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Infoln("config replaced by", r.RemoteAddr)
+		e.configLoaded()
+		w.Write([]byte(fmt.Sprintf("ok, %d connections\n", len(c.Cfgs))))
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "use GET or POST", http.StatusMethodNotAllowed)
+	}
+}
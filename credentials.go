@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// secret is a user/password pair fetched from a credentials provider.
+type secret struct {
+	user, password string
+	expires        time.Time // zero if it does not expire
+}
+
+// credentialsProvider fetches the login of a connection from an external secret store.
+type credentialsProvider interface {
+	fetch(conf *Config) (*secret, error)
+}
+
+// secretLok guards secrets and fetchLoks, a fetch holds only the lock of its key, so a
+// slow secret store does not block the connects using other secrets.
+var (
+	secrets   = make(map[string]*secret)
+	fetchLoks = make(map[string]*sync.Mutex)
+	secretLok sync.Mutex
+)
+
+// cachedSecret returns the secret of key unless it is missing or expired.
+func cachedSecret(key string) *secret {
+	secretLok.Lock()
+	defer secretLok.Unlock()
+	s, ok := secrets[key]
+	if ok && (s.expires.IsZero() || time.Now().Before(s.expires)) {
+		return s
+	}
+	return nil
+}
+
+// provider returns the credentials provider configured for conf and the cache key of its secret,
+// or nil if the password is part of the connection.
+func (conf *Config) provider() (credentialsProvider, string) {
+	switch {
+	case conf.VaultPath != "":
+		return vaultProvider{}, "vault:" + conf.VaultPath
+	case conf.AwsSecret != "":
+		return awsProvider{}, "aws:" + conf.AwsSecret
+	case conf.GcpSecret != "":
+		return gcpProvider{}, "gcp:" + conf.GcpSecret
+	case conf.CyberArk != nil:
+		a := conf.CyberArk
+		return cyberArkProvider{}, "cyberark:" + a.AppID + "/" + a.Safe + "/" + a.Object
+	}
+	return nil, ""
+}
+
+// credentials returns the cached secret of conf, fetching it if missing or expired.
+func credentials(conf *Config) (*secret, error) {
+	p, key := conf.provider()
+	if p == nil {
+		return nil, nil
+	}
+	if s := cachedSecret(key); s != nil {
+		return s, nil
+	}
+	secretLok.Lock()
+	lok := fetchLoks[key]
+	if lok == nil {
+		lok = new(sync.Mutex)
+		fetchLoks[key] = lok
+	}
+	secretLok.Unlock()
+
+	// one fetch per key, the others wait for it and take its result
+	lok.Lock()
+	defer lok.Unlock()
+	if s := cachedSecret(key); s != nil {
+		return s, nil
+	}
+	s, err := p.fetch(conf)
+	if err != nil {
+		return nil, fmt.Errorf("fetch credentials %s: %v", key, err)
+	}
+	log.Infoln("fetched credentials", key)
+	if conf.CyberArk != nil && conf.CyberArk.TTL > 0 {
+		s.expires = time.Now().Add(conf.CyberArk.TTL)
+	}
+	if s.expires.IsZero() && *secretRefresh > 0 {
+		// fetch again from time to time to pick up rotated passwords
+		s.expires = time.Now().Add(*secretRefresh)
+	}
+	secretLok.Lock()
+	secrets[key] = s
+	secretLok.Unlock()
+	return s, nil
+}
+
+// forgetCredentials drops the cached secret of conf, e.g. after ORA-01017 because the
+// password was rotated, so the next connect fetches it again.
+func forgetCredentials(conf *Config) {
+	_, key := conf.provider()
+	secretLok.Lock()
+	defer secretLok.Unlock()
+	delete(secrets, key)
+}
+
+var secretClient = &http.Client{Timeout: 10 * time.Second}
+
+// vaultProvider reads the secret from HashiCorp Vault at VAULT_ADDR with VAULT_TOKEN.
+// KV version 1 and 2 and the database secrets engine are supported, the secret has to
+// contain the keys username (optional) and password.
+type vaultProvider struct{}
+
+func (vaultProvider) fetch(conf *Config) (*secret, error) {
+	addr := os.Getenv("VAULT_ADDR")
+	if addr == "" {
+		return nil, fmt.Errorf("VAULT_ADDR is not set")
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(addr, "/")+"/v1/"+strings.TrimLeft(conf.VaultPath, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", os.Getenv("VAULT_TOKEN"))
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+	resp, err := secretClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault: %s", resp.Status)
+	}
+
+	var body struct {
+		LeaseDuration int                    `json:"lease_duration"`
+		Data          map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	data := body.Data
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		// KV version 2 nests the secret in data.data
+		data = inner
+	}
+	s := &secret{}
+	s.user, _ = data["username"].(string)
+	s.password, _ = data["password"].(string)
+	if s.password == "" {
+		return nil, fmt.Errorf("vault: no password in %s", conf.VaultPath)
+	}
+	if body.LeaseDuration > 0 {
+		s.expires = time.Now().Add(time.Duration(body.LeaseDuration) * time.Second)
+	}
+	return s, nil
+}
+
+// parseSecret reads a secret string, either JSON with username (optional) and password as
+// stored by the AWS/GCP database secret rotation, or the plain password.
+func parseSecret(value []byte) (*secret, error) {
+	value = bytes.TrimSpace(value)
+	var data struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if len(value) > 0 && value[0] == '{' {
+		if err := json.Unmarshal(value, &data); err != nil {
+			return nil, err
+		}
+	} else {
+		data.Password = string(value)
+	}
+	if data.Password == "" {
+		return nil, fmt.Errorf("no password in secret")
+	}
+	return &secret{user: data.Username, password: data.Password}, nil
+}
+
+// commandTimeout limits the run of a CLI by command, a hanging CLI would block the
+// config load or the connect waiting for it.
+const commandTimeout = 30 * time.Second
+
+// command runs a CLI, e.g. a cloud CLI or ldapsearch, and returns its stdout. A cloud CLI
+// takes care of the credential chain (environment, profile, instance or workload identity).
+func command(name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("%s: no result after %s", name, commandTimeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// awsProvider reads the secret from AWS Secrets Manager with the aws CLI.
+type awsProvider struct{}
+
+func (awsProvider) fetch(conf *Config) (*secret, error) {
+	args := []string{"secretsmanager", "get-secret-value", "--secret-id", conf.AwsSecret,
+		"--query", "SecretString", "--output", "text"}
+	// arn:aws:secretsmanager:<region>:<account>:secret:<name>
+	if arn := strings.Split(conf.AwsSecret, ":"); len(arn) > 3 && arn[0] == "arn" {
+		args = append(args, "--region", arn[3])
+	}
+	out, err := command("aws", args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseSecret(out)
+}
+
+// gcpProvider reads the secret from GCP Secret Manager with the gcloud CLI,
+// gcp_secret is projects/<project>/secrets/<name>[/versions/<version>].
+type gcpProvider struct{}
+
+func (gcpProvider) fetch(conf *Config) (*secret, error) {
+	name, version := conf.GcpSecret, "latest"
+	if i := strings.Index(name, "/versions/"); i >= 0 {
+		name, version = name[:i], name[i+len("/versions/"):]
+	}
+	out, err := command("gcloud", "secrets", "versions", "access", version, "--secret", name)
+	if err != nil {
+		return nil, err
+	}
+	return parseSecret(out)
+}
+
+// CyberArk selects the account of a connection in the CyberArk Central Credential Provider.
+// Cert and Key are the client certificate if the CCP authenticates applications by
+// certificate, CA verifies the CCP server. TTL overrides -secrets.refresh.
+type CyberArk struct {
+	URL    string        `yaml:"url"`
+	AppID  string        `yaml:"app_id"`
+	Safe   string        `yaml:"safe"`
+	Object string        `yaml:"object"`
+	Cert   string        `yaml:"cert,omitempty"`
+	Key    string        `yaml:"key,omitempty"`
+	CA     string        `yaml:"ca,omitempty"`
+	TTL    time.Duration `yaml:"ttl,omitempty"`
+}
+
+// client returns the HTTP client for the CCP with the TLS settings of a.
+func (a *CyberArk) client() (*http.Client, error) {
+	if a.Cert == "" && a.CA == "" {
+		return secretClient, nil
+	}
+	cfg := &tls.Config{}
+	if a.Cert != "" {
+		cert, err := tls.LoadX509KeyPair(a.Cert, a.Key)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if a.CA != "" {
+		pem, err := ioutil.ReadFile(a.CA)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", a.CA)
+		}
+	}
+	return &http.Client{Timeout: secretClient.Timeout, Transport: &http.Transport{TLSClientConfig: cfg}}, nil
+}
+
+// cyberArkProvider reads the password from the REST API of the CyberArk Central Credential
+// Provider (AIMWebService) by application ID, safe and object.
+type cyberArkProvider struct{}
+
+func (cyberArkProvider) fetch(conf *Config) (*secret, error) {
+	a := conf.CyberArk
+	client, err := a.client()
+	if err != nil {
+		return nil, fmt.Errorf("cyberark: %v", err)
+	}
+	q := url.Values{"AppID": {a.AppID}, "Object": {a.Object}}
+	if a.Safe != "" {
+		q.Set("Safe", a.Safe)
+	}
+	resp, err := client.Get(strings.TrimRight(a.URL, "/") + "/AIMWebService/api/Accounts?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Content   string
+		UserName  string
+		ErrorCode string
+		ErrorMsg  string
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cyberark: %s %s %s", resp.Status, body.ErrorCode, body.ErrorMsg)
+	}
+	if body.Content == "" {
+		return nil, fmt.Errorf("cyberark: no password in %s", a.Object)
+	}
+	return &secret{user: body.UserName, password: body.Content}, nil
+}
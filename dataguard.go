@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// parseDgInterval parses a lag of v$dataguard_stats like +00 00:00:05 into seconds.
+func parseDgInterval(s string) (float64, error) {
+	var days, hours, mins, secs float64
+	if _, err := fmt.Sscanf(strings.TrimSpace(s), "+%f %f:%f:%f", &days, &hours, &mins, &secs); err != nil {
+		return 0, fmt.Errorf("interval %q: %v", s, err)
+	}
+	return ((days*24+hours)*60+mins)*60 + secs, nil
+}
+
+// ScrapeDataguard collects the transport and apply lag and the apply rate of a standby, and
+// on a primary the lag of every standby destination by the newest log it received and
+// applied, so the divergence of a standby can be alerted on.
+func (e *Exporter) ScrapeDataguard(ctx context.Context, conn *Config) error {
+	if conn.db == nil {
+		return nil
+	}
+	var role string
+	if err := conn.db.QueryRowContext(ctx, `select database_role from v$database`).Scan(&role); err != nil {
+		return err
+	}
+	if role == "PRIMARY" {
+		return e.scrapeDestinationLag(ctx, conn)
+	}
+
+	rows, err := conn.db.QueryContext(ctx, `select name, value from v$dataguard_stats
+                                 where name in ('transport lag', 'apply lag') and value is not null`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var name, value string
+		if err = rows.Scan(&name, &value); err != nil {
+			break
+		}
+		var lag float64
+		if lag, err = parseDgInterval(value); err != nil {
+			break
+		}
+		e.dgLag.WithLabelValues(conn.Database, conn.Instance, strings.TrimSuffix(name, " lag")).Set(lag)
+	}
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	// the apply rate is in v$recovery_progress of the running managed recovery only
+	rows, err = conn.db.QueryContext(ctx, `select item, sofar from v$recovery_progress
+                                 where item in ('Active Apply Rate', 'Average Apply Rate') and units = 'KB/sec'
+                                 and start_time = (select max(start_time) from v$recovery_progress)`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var item string
+		var rate float64
+		if err = rows.Scan(&item, &rate); err != nil {
+			break
+		}
+		typ := strings.ToLower(strings.Fields(item)[0])
+		e.dgRate.WithLabelValues(conn.Database, conn.Instance, typ).Set(rate * 1024)
+	}
+	rows.Close()
+	return err
+}
+
+// scrapeDestinationLag collects the lag of the standby destinations of a primary: the age of
+// the newest log archived to and applied by the destination. An idle primary shows at
+// least the time since its last log switch.
+func (e *Exporter) scrapeDestinationLag(ctx context.Context, conn *Config) error {
+	rows, err := conn.db.QueryContext(ctx, `select s.dest_name, nvl(s.db_unique_name, ' '),
+                                 (select (sysdate - max(l.next_time)) * 86400 from v$archived_log l
+                                  where l.dest_id = s.dest_id and l.resetlogs_change# = d.resetlogs_change#),
+                                 (select (sysdate - max(l.next_time)) * 86400 from v$archived_log l
+                                  where l.dest_id = s.dest_id and l.resetlogs_change# = d.resetlogs_change# and l.applied = 'YES')
+                                 from v$archive_dest_status s, v$database d
+                                 where s.type in ('PHYSICAL', 'LOGICAL', 'SNAPSHOT') and s.status != 'INACTIVE'`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var dest, unique string
+		var transport, apply sql.NullFloat64
+		if err := rows.Scan(&dest, &unique, &transport, &apply); err != nil {
+			return err
+		}
+		unique = strings.TrimSpace(unique)
+		if transport.Valid {
+			e.dgDestLag.WithLabelValues(conn.Database, conn.Instance, dest, unique, "transport").Set(transport.Float64)
+		}
+		if apply.Valid {
+			e.dgDestLag.WithLabelValues(conn.Database, conn.Instance, dest, unique, "apply").Set(apply.Float64)
+		}
+	}
+	return rows.Err()
+}
+
+// ScrapeDgBroker collects the role, protection mode and switchover status of the database,
+// the state of fast-start failover and its observer and the members of the broker
+// configuration with their status, so broker misconfigurations are visible.
+func (e *Exporter) ScrapeDgBroker(ctx context.Context, conn *Config) error {
+	if conn.db == nil {
+		return nil
+	}
+	var role, mode, level, switchover, fsfo, fsfoTarget, observer, observerHost string
+	err := conn.db.QueryRowContext(ctx, `select database_role, protection_mode, protection_level, switchover_status,
+                                 fs_failover_status, nvl(fs_failover_current_target, ' '),
+                                 nvl(fs_failover_observer_present, ' '), nvl(fs_failover_observer_host, ' ')
+                                 from v$database`).Scan(&role, &mode, &level, &switchover, &fsfo, &fsfoTarget, &observer, &observerHost)
+	if err != nil {
+		return err
+	}
+	e.dgStatus.WithLabelValues(conn.Database, conn.Instance, role, mode, level, switchover).Set(1)
+	e.fsfoStatus.WithLabelValues(conn.Database, conn.Instance, fsfo, strings.TrimSpace(fsfoTarget)).Set(1)
+	present := 0.0
+	if observer == "YES" {
+		present = 1
+	}
+	e.fsfoObsrv.WithLabelValues(conn.Database, conn.Instance, strings.TrimSpace(observerHost)).Set(present)
+
+	rows, err := conn.db.QueryContext(ctx, `select database, dataguard_role, enabled, status from v$dg_broker_config`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var member, memberRole, enabled string
+		var status float64
+		if err := rows.Scan(&member, &memberRole, &enabled, &status); err != nil {
+			return err
+		}
+		e.dgMember.WithLabelValues(conn.Database, conn.Instance, member, memberRole, enabled).Set(status)
+	}
+	return rows.Err()
+}
+
+// oraCodeRe matches the ORA- error number of an error message.
+var oraCodeRe = regexp.MustCompile(`ORA-(\d+)`)
+
+// ScrapeArchiveDest collects the status and the error of every active archive destination and
+// the log sequences its archived logs are behind the current log of their thread, to catch
+// broken log shipping.
+func (e *Exporter) ScrapeArchiveDest(ctx context.Context, conn *Config) error {
+	if conn.db == nil {
+		return nil
+	}
+	rows, err := conn.db.QueryContext(ctx, `select d.dest_name, nvl(d.destination, ' '), d.status, nvl(d.error, ' '),
+                                 (select max(l.sequence#) from v$log l where l.status = 'CURRENT' and l.thread# = s.archived_thread#) - s.archived_seq#
+                                 from v$archive_dest d join v$archive_dest_status s on s.dest_id = d.dest_id
+                                 where d.status != 'INACTIVE'`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, dest, status, msg string
+		var gap sql.NullFloat64
+		if err := rows.Scan(&name, &dest, &status, &msg, &gap); err != nil {
+			return err
+		}
+		dest = strings.TrimSpace(dest)
+		valid := 0.0
+		if status == "VALID" {
+			valid = 1
+		}
+		e.archDest.WithLabelValues(conn.Database, conn.Instance, name, dest, status).Set(valid)
+		code := 0.0
+		if m := oraCodeRe.FindStringSubmatch(msg); m != nil {
+			code, _ = strconv.ParseFloat(m[1], 64)
+		}
+		e.archErr.WithLabelValues(conn.Database, conn.Instance, name, dest).Set(code)
+		if gap.Valid {
+			e.archGap.WithLabelValues(conn.Database, conn.Instance, name, dest).Set(gap.Float64)
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+)
+
+// seriesValues returns the value of every series of set by target (database/dbinstance,
+// "-" for series without database) and series text.
+func (e *Exporter) seriesValues(set *metricSet) map[string]map[string]float64 {
+	result := make(map[string]map[string]float64)
+	if set == nil {
+		return result
+	}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectorFunc((&Exporter{exporterState: e.exporterState, metricSet: set}).collectMetrics))
+	mfs, err := reg.Gather()
+	if err != nil {
+		log.Warnln("diff gather:", err)
+	}
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			target := label(m, "database") + "/" + label(m, "dbinstance")
+			if label(m, "database") == "" {
+				target = "-"
+			}
+			if result[target] == nil {
+				result[target] = make(map[string]float64)
+			}
+			result[target][seriesName(mf.GetName(), m)] = sampleValue(m)
+		}
+	}
+	return result
+}
+
+// seriesName returns the series of m in the text format, name{label="value",...}.
+func seriesName(name string, m *dto.Metric) string {
+	var labels []string
+	for _, lp := range m.GetLabel() {
+		labels = append(labels, fmt.Sprintf("%s=%q", lp.GetName(), lp.GetValue()))
+	}
+	return name + "{" + strings.Join(labels, ",") + "}"
+}
+
+// sampleValue returns the value of a gauge, counter or untyped metric, the sample count of others.
+func sampleValue(m *dto.Metric) float64 {
+	switch {
+	case m.Gauge != nil:
+		return m.GetGauge().GetValue()
+	case m.Counter != nil:
+		return m.GetCounter().GetValue()
+	case m.Untyped != nil:
+		return m.GetUntyped().GetValue()
+	case m.Histogram != nil:
+		return float64(m.GetHistogram().GetSampleCount())
+	case m.Summary != nil:
+		return float64(m.GetSummary().GetSampleCount())
+	}
+	return 0
+}
+
+// DiffHandler writes the series which appeared (+), disappeared (-) or changed their value
+// (~) between the last two full scrapes per target, to find flapping series caused by the
+// order of query results, rownum labels or dictionary churn. target=X limits it to one
+// database or instance, changed=0 leaves out the changed values.
+func (e *Exporter) DiffHandler(w http.ResponseWriter, r *http.Request) {
+	e.lastLok.Lock()
+	prev, last := e.prev, e.last
+	e.lastLok.Unlock()
+	if prev == nil {
+		http.Error(w, "no two scrapes yet", http.StatusServiceUnavailable)
+		return
+	}
+	filter := r.URL.Query().Get("target")
+	changed := r.URL.Query().Get("changed") != "0"
+	before, after := e.seriesValues(prev), e.seriesValues(last)
+
+	targets := make(map[string]bool)
+	for t := range before {
+		targets[t] = true
+	}
+	for t := range after {
+		targets[t] = true
+	}
+	var names []string
+	for t := range targets {
+		if filter == "" || strings.HasPrefix(t, filter+"/") || strings.HasSuffix(t, "/"+filter) {
+			names = append(names, t)
+		}
+	}
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, t := range names {
+		var lines []string
+		for s, v := range after[t] {
+			if old, ok := before[t][s]; !ok {
+				lines = append(lines, fmt.Sprintf("+ %s %g", s, v))
+			} else if changed && old != v {
+				lines = append(lines, fmt.Sprintf("~ %s %g -> %g", s, old, v))
+			}
+		}
+		for s, v := range before[t] {
+			if _, ok := after[t][s]; !ok {
+				lines = append(lines, fmt.Sprintf("- %s %g", s, v))
+			}
+		}
+		sort.Slice(lines, func(i, j int) bool { return lines[i][2:] < lines[j][2:] })
+		fmt.Fprintf(w, "# %s: %d series before, %d after, %d differences\n", t, len(before[t]), len(after[t]), len(lines))
+		for _, line := range lines {
+			fmt.Fprintln(w, line)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// SrvTarget creates a connection from Template for each host and port of the DNS SRV record Name.
+type SrvTarget struct {
+	Name     string `yaml:"name"`
+	Template Config `yaml:"template"`
+	included bool
+}
+
+// State of the discovered targets, guarded by cfgLok.
+var (
+	// targetsSig is the name, size and modification time of the files read last from
+	// targets_file, they are only read again when it changes
+	targetsSig  string
+	fileTargets []Config
+	srvCache    map[string][]Config
+	// configSeq is incremented by each loadConfig
+	configSeq int
+)
+
+// targetFiles returns the files of targets_file, the file itself or the *.yml and *.yaml
+// files of a directory.
+func targetFiles(path string) ([]string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return []string{path}, nil
+	}
+	var files []string
+	for _, pattern := range []string{"*.yml", "*.yaml"} {
+		m, err := filepath.Glob(filepath.Join(path, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, m...)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// targetsSignature returns the signature of files, empty if one can not be read.
+func targetsSignature(files []string) string {
+	var b strings.Builder
+	for _, file := range files {
+		fi, err := os.Stat(file)
+		if err != nil {
+			return ""
+		}
+		fmt.Fprintf(&b, "%s %d %d\n", file, fi.Size(), fi.ModTime().UnixNano())
+	}
+	return b.String()
+}
+
+// readTargets reads the connections of targets_file, like the file_sd of Prometheus each
+// file holds a YAML list of connections in the format of oracle.conf.
+func readTargets(path string, ldap *Ldap, defaults *Defaults) ([]Config, string, error) {
+	files, err := targetFiles(path)
+	if err != nil {
+		return nil, "", err
+	}
+	sig := targetsSignature(files)
+	c := Configs{Ldap: ldap, Defaults: defaults}
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, "", err
+		}
+		var cfgs []Config
+		if err := yaml.UnmarshalStrict(content, &cfgs); err != nil {
+			return nil, "", fmt.Errorf("%s: %v", file, err)
+		}
+		if len(cfgs) > 0 {
+			if err := validateConfig(Configs{Cfgs: cfgs}); err != nil {
+				return nil, "", fmt.Errorf("%s: %v", file, err)
+			}
+		}
+		c.Cfgs = append(c.Cfgs, cfgs...)
+	}
+	expandConfig(&c)
+	if err := decryptPasswords(c.Cfgs); err != nil {
+		return nil, "", err
+	}
+	for i := range c.Cfgs {
+		c.Cfgs[i].discovered = true
+	}
+	return c.Cfgs, sig, nil
+}
+
+// targetKey identifies a connection by its settings, unchanged targets keep their open connection.
+func targetKey(conf Config) string {
+	out, _ := yaml.Marshal(conf)
+	return string(out)
+}
+
+// mergeTargets replaces the discovered connections of config by targets and returns the
+// number of added and removed connections. The caller holds cfgLok.
+func mergeTargets(targets []Config) (added, removed int) {
+	old := make(map[string]Config)
+	cfgs := make([]Config, 0, len(config.Cfgs)+len(targets))
+	for _, conf := range config.Cfgs {
+		if conf.discovered {
+			old[targetKey(conf)] = conf
+		} else {
+			cfgs = append(cfgs, conf)
+		}
+	}
+	for _, conf := range targets {
+		key := targetKey(conf)
+		if prev, ok := old[key]; ok {
+			conf = prev
+			delete(old, key)
+		} else {
+			added++
+		}
+		cfgs = append(cfgs, conf)
+	}
+	removed = len(old)
+	gone := Configs{}
+	for _, conf := range old {
+		gone.Cfgs = append(gone.Cfgs, conf)
+	}
+	go CloseConnection(gone)
+	config.Cfgs = cfgs
+	return added, removed
+}
+
+// srvTargets creates a connection from the template for each host and port the SRV record
+// name resolves to. Placeholders {host} and {port} are replaced in connection, database and
+// instance.
+func srvTargets(srv SrvTarget, ldap *Ldap, defaults *Defaults) ([]Config, error) {
+	_, addrs, err := net.LookupSRV("", "", srv.Name)
+	if err != nil {
+		return nil, err
+	}
+	c := Configs{Ldap: ldap, Defaults: defaults}
+	for _, addr := range addrs {
+		r := strings.NewReplacer("{host}", strings.TrimSuffix(addr.Target, "."), "{port}", strconv.Itoa(int(addr.Port)))
+		conf := srv.Template
+		conf.Connection = r.Replace(conf.Connection)
+		conf.Database = r.Replace(conf.Database)
+		conf.Instance = r.Replace(conf.Instance)
+		conf.discovered = true
+		c.Cfgs = append(c.Cfgs, conf)
+	}
+	expandConfig(&c)
+	if err := decryptPasswords(c.Cfgs); err != nil {
+		return nil, err
+	}
+	return c.Cfgs, nil
+}
+
+// resolveSrv resolves all srv_targets, a record failing to resolve keeps its targets in prev.
+func resolveSrv(srvs []SrvTarget, ldap *Ldap, defaults *Defaults, prev map[string][]Config) map[string][]Config {
+	resolved := make(map[string][]Config)
+	for _, srv := range srvs {
+		cfgs, err := srvTargets(srv, ldap, defaults)
+		if err != nil {
+			log.Errorln("srv_targets:", err)
+			cfgs = prev[srv.Name]
+		}
+		resolved[srv.Name] = cfgs
+	}
+	return resolved
+}
+
+// discoveredTargets returns the connections of targets_file and srv_targets in config order.
+func discoveredTargets(file []Config, srvs []SrvTarget, resolved map[string][]Config) []Config {
+	targets := append([]Config(nil), file...)
+	for _, srv := range srvs {
+		targets = append(targets, resolved[srv.Name]...)
+	}
+	return targets
+}
+
+// watchTargets merges the connections of targets_file, when the files changed, and of
+// srv_targets into the config every -targets.interval, so automation can add and remove
+// databases without editing oracle.conf or restarting.
+func watchTargets(e *Exporter) {
+	for range time.Tick(*targetsPoll) {
+		cfgLok.Lock()
+		path, srvs, ldap, defaults, seq := config.TargetsFile, config.SrvTargets, config.Ldap, config.Defaults, configSeq
+		file, sig, prev := fileTargets, targetsSig, srvCache
+		cfgLok.Unlock()
+		if path == "" && len(srvs) == 0 {
+			continue
+		}
+		if path != "" {
+			files, err := targetFiles(path)
+			if err != nil || targetsSignature(files) != sig {
+				if targets, newSig, err := readTargets(path, ldap, defaults); err != nil {
+					// keep the targets of the last good read
+					log.Errorln("targets_file:", err)
+				} else {
+					file, sig = targets, newSig
+				}
+			}
+		}
+		resolved := resolveSrv(srvs, ldap, defaults, prev)
+
+		cfgLok.Lock()
+		if configSeq != seq {
+			// config reloaded meanwhile
+			cfgLok.Unlock()
+			continue
+		}
+		fileTargets, targetsSig, srvCache = file, sig, resolved
+		targets := discoveredTargets(file, srvs, resolved)
+		added, removed := mergeTargets(targets)
+		cfgLok.Unlock()
+		if added+removed > 0 {
+			log.Infof("discovered %d connections, %d added, %d removed", len(targets), added, removed)
+			addCustomsql(e)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"strconv"
+)
+
+// driverModule is the module path of go-ora in the build info.
+const driverModule = "github.com/sijms/go-ora/v2"
+
+// driverVersion is the go-ora version the exporter was built with.
+var driverVersion = func() string {
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range bi.Deps {
+			if dep.Path == driverModule {
+				return dep.Version
+			}
+		}
+	}
+	return "unknown"
+}()
+
+// driverInfo is what go-ora negotiated with the server on a connection, for issues that only
+// appear with some server versions or protocol fallbacks.
+type driverInfo struct {
+	protocol string // TNS protocol version of the server
+	ttc      string // TTC version used, the lower of client and server
+	server   string // server version as parsed by the driver
+}
+
+// labels returns the label values of oracledb_exporter_driver_info of conf.
+func (d *driverInfo) labels(conf *Config) []string {
+	return []string{conf.Database, conf.Instance, "go-ora", driverVersion, d.protocol, d.ttc, d.server}
+}
+
+// connDriverInfo reads the driver info of a connection of db. go-ora does not export the
+// negotiated values, they are read from its connection by reflection.
+func connDriverInfo(ctx context.Context, db *sql.DB) (*driverInfo, error) {
+	c, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	d := &driverInfo{}
+	err = c.Raw(func(dc interface{}) error {
+		conn := reflect.ValueOf(dc)
+		d.protocol = fieldString(conn, "tcpNego", "ProtocolServerVersion")
+		d.ttc = fieldString(conn, "session", "TTCVersion")
+		major := fieldString(conn, "dBVersion", "MajorVersion")
+		if major == "" {
+			return fmt.Errorf("unknown go-ora connection %T", dc)
+		}
+		d.server = major + "." + fieldString(conn, "dBVersion", "MinorVersion") + "." + fieldString(conn, "dBVersion", "PatchsetVersion")
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// fieldString returns the number or string at the field path of the struct v points to,
+// empty if there is none.
+func fieldString(v reflect.Value, path ...string) string {
+	for _, name := range path {
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return ""
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return ""
+		}
+		if v = v.FieldByName(name); !v.IsValid() {
+			return ""
+		}
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.String:
+		return v.String()
+	}
+	return ""
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// masterKey returns the AES key of password_encrypted, read from -master-key.file or
+// decrypted by AWS KMS from -master-key.kms. The key is 16, 24 or 32 bytes, raw or
+// base64 encoded, e.g. from openssl rand -base64 32.
+func masterKey() ([]byte, error) {
+	var content []byte
+	var err error
+	switch {
+	case *masterKeyFile != "":
+		content, err = ioutil.ReadFile(*masterKeyFile)
+	case *masterKeyKms != "":
+		// the data key encrypted with aws kms encrypt, the plaintext is returned base64 encoded
+		content, err = command("aws", "kms", "decrypt", "--ciphertext-blob", "fileb://"+*masterKeyKms,
+			"--query", "Plaintext", "--output", "text")
+	default:
+		return nil, fmt.Errorf("password_encrypted needs -master-key.file or -master-key.kms")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("master key: %v", err)
+	}
+	if key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content))); err == nil && validKeySize(len(key)) {
+		return key, nil
+	}
+	if !validKeySize(len(content)) {
+		return nil, fmt.Errorf("master key: %d bytes, 16, 24 or 32 required", len(content))
+	}
+	return content, nil
+}
+
+func validKeySize(n int) bool {
+	return n == 16 || n == 24 || n == 32
+}
+
+// encryptPassword returns the base64 encoded nonce and AES-GCM ciphertext of password.
+func encryptPassword(key []byte, password string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(password), nil)), nil
+}
+
+// decryptPassword reverses encryptPassword.
+func decryptPassword(key []byte, encrypted string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encrypted))
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("too short")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		// wrong key or modified ciphertext
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// decryptPasswords decrypts the password_encrypted of all connections in cfgs, the master
+// key is only read if there is one.
+func decryptPasswords(cfgs []Config) error {
+	var key []byte
+	for i := range cfgs {
+		conf := &cfgs[i]
+		if conf.EncPassword == "" {
+			continue
+		}
+		if key == nil {
+			var err error
+			if key, err = masterKey(); err != nil {
+				return err
+			}
+		}
+		password, err := decryptPassword(key, conf.EncPassword)
+		if err != nil {
+			return fmt.Errorf("connection %s: password_encrypted: %v", conf.Database, err)
+		}
+		conf.password = password
+	}
+	return nil
+}
+
+// printEncrypted reads a password from r and writes its password_encrypted value to w.
+func printEncrypted(r io.Reader, w io.Writer) error {
+	key, err := masterKey()
+	if err != nil {
+		return err
+	}
+	password, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		return fmt.Errorf("no password on stdin")
+	}
+	encrypted, err := encryptPassword(key, password)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, encrypted)
+	return err
+}
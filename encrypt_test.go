@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      []byte
+		password string
+	}{
+		{"aes-128", bytes.Repeat([]byte{1}, 16), "secret"},
+		{"aes-192", bytes.Repeat([]byte{2}, 24), "p@ss w0rd/with:special"},
+		{"aes-256", bytes.Repeat([]byte{3}, 32), ""},
+		{"unicode", bytes.Repeat([]byte{4}, 32), "pässwört"},
+	}
+	for _, tt := range tests {
+		encrypted, err := encryptPassword(tt.key, tt.password)
+		if err != nil {
+			t.Errorf("%s: encrypt: %v", tt.name, err)
+			continue
+		}
+		if tt.password != "" && bytes.Contains([]byte(encrypted), []byte(tt.password)) {
+			t.Errorf("%s: %q contains the password", tt.name, encrypted)
+		}
+		got, err := decryptPassword(tt.key, encrypted)
+		if err != nil {
+			t.Errorf("%s: decrypt: %v", tt.name, err)
+			continue
+		}
+		if got != tt.password {
+			t.Errorf("%s: decrypted %q, want %q", tt.name, got, tt.password)
+		}
+		wrong := bytes.Repeat([]byte{9}, len(tt.key))
+		if _, err := decryptPassword(wrong, encrypted); err == nil {
+			t.Errorf("%s: decrypted with the wrong key", tt.name)
+		}
+	}
+
+	if _, err := encryptPassword([]byte("short"), "secret"); err == nil {
+		t.Error("encrypted with a 5 byte key")
+	}
+	key := bytes.Repeat([]byte{1}, 16)
+	for _, bad := range []string{"not base64!", "AAAA"} {
+		if _, err := decryptPassword(key, bad); err == nil {
+			t.Errorf("decrypted %q", bad)
+		}
+	}
+}
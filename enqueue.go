@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+)
+
+// ScrapeEnqueue collects the requests, waits and wait time per enqueue type since the start
+// of the instance, e.g. TX for row lock and TM for DML lock contention, as counters so their
+// trends can be followed with rate().
+func (e *Exporter) ScrapeEnqueue(ctx context.Context, conn *Config) error {
+	if conn.db == nil {
+		return nil
+	}
+	rows, err := conn.db.QueryContext(ctx, `select eq_type, sum(total_req#), sum(total_wait#), sum(cum_wait_time) / 1000
+                                 from v$enqueue_stat
+                                 where total_req# > 0
+                                 group by eq_type`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var typ string
+		var requests, waits, seconds float64
+		if err := rows.Scan(&typ, &requests, &waits, &seconds); err != nil {
+			return err
+		}
+		e.enqReqs.Set(requests, conn.Database, conn.Instance, typ)
+		e.enqWaits.Set(waits, conn.Database, conn.Instance, typ)
+		e.enqWaitSec.Set(seconds, conn.Database, conn.Instance, typ)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+)
+
+// errDivZero is the error of a division by zero, a value of the data rather than a broken
+// expression.
+var errDivZero = errors.New("division by zero")
+
+// exprFunc is a function callable in an expression with string literal arguments.
+type exprFunc func(args ...string) (float64, error)
+
+// evalExpr evaluates an arithmetic expression like "used/total*100", identifiers are
+// looked up (by cleanName) in vars. Supported are numbers, parenthesis, unary +/- and + - * /,
+// the comparisons == != < <= > >= and ! && || with 1 for true and 0 for false.
+func evalExpr(expr string, vars map[string]float64) (float64, error) {
+	return evalFuncs(expr, vars, nil)
+}
+
+// evalFuncs is evalExpr with the functions funcs, called like name("arg").
+func evalFuncs(expr string, vars map[string]float64, funcs map[string]exprFunc) (float64, error) {
+	node, err := parser.ParseExpr(expr)
+	if err != nil {
+		return 0, fmt.Errorf("parse %q: %v", expr, err)
+	}
+	return evalNode(node, vars, funcs)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func evalNode(node ast.Expr, vars map[string]float64, funcs map[string]exprFunc) (float64, error) {
+	switch n := node.(type) {
+	case *ast.BasicLit:
+		if n.Kind != token.INT && n.Kind != token.FLOAT {
+			return 0, fmt.Errorf("unsupported literal %s", n.Value)
+		}
+		return strconv.ParseFloat(n.Value, 64)
+	case *ast.Ident:
+		v, ok := vars[cleanName(n.Name)]
+		if !ok {
+			return 0, fmt.Errorf("unknown column %s", n.Name)
+		}
+		return v, nil
+	case *ast.ParenExpr:
+		return evalNode(n.X, vars, funcs)
+	case *ast.CallExpr:
+		name, ok := n.Fun.(*ast.Ident)
+		if !ok || funcs[name.Name] == nil {
+			return 0, fmt.Errorf("unknown function")
+		}
+		var args []string
+		for _, arg := range n.Args {
+			lit, ok := arg.(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return 0, fmt.Errorf("%s: arguments must be strings", name.Name)
+			}
+			s, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				return 0, err
+			}
+			args = append(args, s)
+		}
+		return funcs[name.Name](args...)
+	case *ast.UnaryExpr:
+		x, err := evalNode(n.X, vars, funcs)
+		if err != nil {
+			return 0, err
+		}
+		switch n.Op {
+		case token.ADD:
+			return x, nil
+		case token.SUB:
+			return -x, nil
+		case token.NOT:
+			return boolValue(x == 0), nil
+		}
+		return 0, fmt.Errorf("unsupported operator %s", n.Op)
+	case *ast.BinaryExpr:
+		x, err := evalNode(n.X, vars, funcs)
+		if err != nil {
+			return 0, err
+		}
+		y, err := evalNode(n.Y, vars, funcs)
+		if err != nil {
+			return 0, err
+		}
+		switch n.Op {
+		case token.ADD:
+			return x + y, nil
+		case token.SUB:
+			return x - y, nil
+		case token.MUL:
+			return x * y, nil
+		case token.QUO:
+			if y == 0 {
+				return 0, errDivZero
+			}
+			return x / y, nil
+		case token.EQL:
+			return boolValue(x == y), nil
+		case token.NEQ:
+			return boolValue(x != y), nil
+		case token.LSS:
+			return boolValue(x < y), nil
+		case token.LEQ:
+			return boolValue(x <= y), nil
+		case token.GTR:
+			return boolValue(x > y), nil
+		case token.GEQ:
+			return boolValue(x >= y), nil
+		case token.LAND:
+			return boolValue(x != 0 && y != 0), nil
+		case token.LOR:
+			return boolValue(x != 0 || y != 0), nil
+		}
+		return 0, fmt.Errorf("unsupported operator %s", n.Op)
+	}
+	return 0, fmt.Errorf("unsupported expression %T", node)
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestEvalExpr(t *testing.T) {
+	vars := map[string]float64{"used": 25, "total": 200, "zero": 0}
+	tests := []struct {
+		expr    string
+		want    float64
+		wantErr bool
+	}{
+		{"used/total*100", 12.5, false},
+		{"(total - used) / 2", 87.5, false},
+		{"-used + 5", -20, false},
+		{"used < total", 1, false},
+		{"used >= total || !zero", 1, false},
+		{"used == 25 && total != 200", 0, false},
+		{"used / zero", 0, true},
+		{"unknown + 1", 0, true},
+		{"used +", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := evalExpr(tt.expr, vars)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("evalExpr(%q) error %v, want error %v", tt.expr, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("evalExpr(%q) = %g, want %g", tt.expr, got, tt.want)
+		}
+	}
+	if _, err := evalExpr("used / zero", vars); err != errDivZero {
+		t.Errorf("division by zero returned %v, want errDivZero", err)
+	}
+}
@@ -3,12 +3,14 @@ module prometheus_oracle_exporter
 go 1.16
 
 require (
-	github.com/golang/protobuf v1.5.2 // indirect
+	github.com/golang/protobuf v1.5.2
 	github.com/prometheus/client_golang v1.11.0
-	github.com/prometheus/common v0.30.0 // indirect
+	github.com/prometheus/client_model v0.2.0
+	github.com/prometheus/common v0.30.0
 	github.com/prometheus/procfs v0.7.2 // indirect
 	github.com/sijms/go-ora/v2 v2.1.27
 	github.com/sirupsen/logrus v1.8.1
+	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871
 	golang.org/x/sys v0.0.0-20210816032535-30e4713e60e3 // indirect
 	google.golang.org/protobuf v1.27.1 // indirect
 	gopkg.in/yaml.v2 v2.4.0
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// grantViews lists the dictionary views read by each collector, opt is the flag enabling it,
+// nil for the optional collectors enabled by -collectors.optional. needs is the version,
+// feature or license the views need, shown in the script.
+var grantViews = []struct {
+	opt   *bool
+	name  string
+	views []string
+	needs string
+}{
+	{nil, "connect", []string{"v$database", "v$instance"}, ""},
+	{pMetrics, "uptime", []string{"v$instance"}, ""},
+	{pMetrics, "session", []string{"v$session"}, ""},
+	{pMetrics, "sysstat", []string{"v$sysstat"}, ""},
+	{pMetrics, "waitclass", []string{"v$waitclassmetric", "v$system_wait_class"}, ""},
+	{pMetrics, "sysmetric, aas, cache", []string{"v$sysmetric"}, ""},
+	{pMetrics, "tablespace", []string{"dba_data_files", "dba_lmt_free_space", "v$tablespace", "dba_tablespaces", "dba_temp_files"}, ""},
+	{pMetrics, "interconnect", []string{"v$sysstat", "v$dynamic_remaster_stats", "v$system_event"}, "RAC"},
+	{pMetrics, "redo", []string{"v$log_history"}, ""},
+	{pMetrics, "services", []string{"v$active_services"}, ""},
+	{pMetrics, "parameter", []string{"v$parameter"}, ""},
+	{pMetrics, "components", []string{"dba_registry"}, ""},
+	{pMetrics, "directories", []string{"dba_directories", "dba_external_tables"}, ""},
+	{pMetrics, "patch", []string{"dba_registry_sqlpatch"}, "12c+"},
+	{pMetrics, "asmspace, locations", []string{"v$asm_disk_stat", "v$asm_diskgroup_stat", "v$asm_operation", "dba_data_files", "dba_temp_files"}, ""},
+	{pMetrics, "tempundo", []string{"v$tempundostat", "v$tempseg_usage", "dba_tablespaces"}, "12c+"},
+	{pMetrics, "cursors", []string{"v$sesstat", "v$statname", "v$session"}, ""},
+	{nil, "parameterchanges", []string{"v$parameter"}, ""},
+	{nil, "pdbs", []string{"v$pdbs", "pdb_plug_in_violations"}, "12c+ multitenant"},
+	{nil, "jobs", []string{"dba_datapump_jobs", "dba_objects", "v$rman_status"}, ""},
+	{nil, "dbsize", []string{"dba_data_files", "dba_segments", "dba_temp_files", "v$log"}, ""},
+	{nil, "awr", []string{"v$parameter", "dba_hist_wr_control", "dba_hist_snapshot"}, "dba_hist_* need the Diagnostics Pack license"},
+	{nil, "memory", []string{"v$sgainfo", "v$sga_dynamic_components", "v$pgastat"}, ""},
+	{nil, "dataguard", []string{"v$dataguard_stats", "v$recovery_progress", "v$archive_dest_status", "v$archived_log"}, "Data Guard, v$dataguard_stats has rows on a standby only"},
+	{nil, "dgbroker", []string{"v$dg_broker_config"}, "Data Guard broker"},
+	{nil, "archivedest", []string{"v$archive_dest", "v$archive_dest_status", "v$log"}, ""},
+	{nil, "blocking", []string{"v$session"}, ""},
+	{nil, "enqueue", []string{"v$enqueue_stat"}, ""},
+	{nil, "longops", []string{"v$session_longops"}, ""},
+	{nil, "undo", []string{"dba_undo_extents", "v$undostat", "v$tablespace"}, ""},
+	{nil, "temp", []string{"v$temp_space_header", "dba_tablespaces", "v$sort_segment"}, ""},
+	{nil, "resource", []string{"v$resource_limit"}, ""},
+	{pRecovery, "recovery", []string{"v$flash_recovery_area_usage"}, ""},
+	{pTabRows, "tablerows", []string{"dba_tables"}, ""},
+	{pTabBytes, "tablebytes", []string{"dba_tables", "dba_segments"}, ""},
+	{pIndBytes, "indexbytes", []string{"dba_indexes", "dba_segments"}, ""},
+	{pLobBytes, "lobbytes", []string{"dba_lobs", "dba_segments"}, ""},
+	{pObjChange, "objectchanges", []string{"dba_objects"}, ""},
+	{pUserStats, "userstats", []string{"v$sesstat", "v$statname", "v$session"}, ""},
+	{pSecurity, "security", []string{"v$option", "dba_audit_trail", "unified_audit_trail", "dba_users"}, "unified_audit_trail 12c+"},
+}
+
+// grantEnabled reports whether the collector name of grantViews with the flag opt runs.
+func grantEnabled(opt *bool, name string) bool {
+	for _, c := range optionalCollectors {
+		if c == name {
+			return optionalEnabled[name]
+		}
+	}
+	return opt == nil || *opt
+}
+
+var dictViewRe = regexp.MustCompile(`(?i)\b(g?v\$\w+|dba_\w+|cdb_\w+)`)
+
+// grantObject returns the SYS object to grant for a dictionary view, v$ views are synonyms of v_$.
+// Views qualified with their owner are granted as they are.
+func grantObject(view string) string {
+	view = strings.ToLower(view)
+	if strings.Contains(view, ".") {
+		return view
+	}
+	view = strings.Replace(view, "v$", "v_$", 1)
+	return "sys." + view
+}
+
+// printGrants writes the CREATE USER/GRANT script for a monitoring account able to run
+// the enabled collectors and the custom queries of the configuration.
+func printGrants(w io.Writer) {
+	user := *grantsUser
+	container := ""
+	if *grantsCdb {
+		container = " CONTAINER=ALL"
+	}
+
+	fmt.Fprintf(w, "-- monitoring account for prometheus_oracle_exporter %s\n", Version)
+	fmt.Fprintf(w, "CREATE USER %s IDENTIFIED BY \"<password>\"%s;\n", user, container)
+	fmt.Fprintf(w, "GRANT CREATE SESSION TO %s%s;\n", user, container)
+	if *grantsCdb {
+		// common user must see the data of all PDBs in the v$ views
+		fmt.Fprintf(w, "ALTER USER %s SET CONTAINER_DATA=ALL CONTAINER=CURRENT;\n", user)
+	}
+
+	granted := make(map[string]bool)
+	section := func(title string, views []string, enabled bool) {
+		prefix := ""
+		if !enabled {
+			prefix = "-- "
+		}
+		// a section whose views were all granted before is left out
+		var lines []string
+		for _, view := range views {
+			obj := grantObject(view)
+			if granted[obj] {
+				continue
+			}
+			if enabled {
+				granted[obj] = true
+			}
+			lines = append(lines, fmt.Sprintf("%sGRANT SELECT ON %s TO %s%s;", prefix, obj, user, container))
+		}
+		if len(lines) > 0 {
+			fmt.Fprintf(w, "\n-- %s\n%s\n", title, strings.Join(lines, "\n"))
+		}
+	}
+
+	for _, g := range grantViews {
+		title := g.name
+		if g.needs != "" {
+			title += " (" + g.needs + ")"
+		}
+		if grantEnabled(g.opt, g.name) {
+			section(title, g.views, true)
+		} else {
+			section(title+" (not enabled)", g.views, false)
+		}
+	}
+
+	cfgLok.Lock()
+	defer cfgLok.Unlock()
+	for _, conn := range config.Cfgs {
+		if conn.Oem != nil {
+			// on the repository database only
+			section("oem", oemViews, true)
+			break
+		}
+	}
+	aqSeen := make(map[string]bool)
+	for _, conn := range config.Cfgs {
+		if conn.Aq != nil && !aqSeen[conn.Database] {
+			aqSeen[conn.Database] = true
+			section("aq of "+conn.Database, append(append([]string(nil), aqViews...), conn.Aq.QueueTables...), true)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, conn := range config.Cfgs {
+		for _, query := range conn.Queries {
+			if seen[query.Name] {
+				continue
+			}
+			seen[query.Name] = true
+			views := dictViewRe.FindAllString(query.Sql, -1)
+			if query.Rac {
+				views = append(dictViewRe.FindAllString(racSQL(query.Sql, "1"), -1), "gv$instance")
+			}
+			section("custom query "+query.Name, views, true)
+		}
+	}
+}
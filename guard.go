@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// scrapeGuard limits the scrapes running at the same time to -web.max-concurrent-scrapes,
+// so two Prometheus servers scraping at once do not run every query twice in parallel.
+// A request waiting for a slot is answered with the result of a scrape with the same
+// parameters finished meanwhile instead of scraping again.
+type scrapeGuard struct {
+	slots chan struct{}
+	lok   sync.Mutex
+	last  map[string]guardResult // by scrapeOptions.key
+}
+
+// guardResult is the result of the last scrape with some parameters.
+type guardResult struct {
+	done time.Time
+	mfs  []*dto.MetricFamily
+	err  error
+}
+
+func newScrapeGuard(n int) *scrapeGuard {
+	return &scrapeGuard{slots: make(chan struct{}, n), last: make(map[string]guardResult)}
+}
+
+// gatherer returns g running within the limit for a request with ctx and opts. shared
+// counts the requests answered with the result of another one.
+func (s *scrapeGuard) gatherer(ctx context.Context, opts *scrapeOptions, g prometheus.Gatherer, shared prometheus.Counter) prometheus.Gatherer {
+	key := opts.key()
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		begun := time.Now()
+		select {
+		case s.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		defer func() { <-s.slots }()
+
+		s.lok.Lock()
+		r, ok := s.last[key]
+		s.lok.Unlock()
+		if ok && r.done.After(begun) {
+			shared.Inc()
+			return r.mfs, r.err
+		}
+
+		mfs, err := g.Gather()
+		s.lok.Lock()
+		s.last[key] = guardResult{time.Now(), mfs, err}
+		s.lok.Unlock()
+		return mfs, err
+	})
+}
+
+// full reports whether the scrape covers all connections and collectors of the regular
+// scrapes, so its series can be compared with the previous full scrape by /debug/diff.
+func (o *scrapeOptions) full() bool {
+	return o.target == "" && len(o.collect) == 0 && !o.debug && !o.heavy
+}
+
+// key identifies the scrapes returning the same series.
+func (o *scrapeOptions) key() string {
+	k := *o
+	k.remoteIP, k.timeout = "", 0
+	return fmt.Sprintf("%+v", k)
+}
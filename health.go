@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+)
+
+// targetMetrics returns the metrics of c belonging to conn.
+func targetMetrics(c prometheus.Collector, conn *Config) []*dto.Metric {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	var result []*dto.Metric
+	for pm := range ch {
+		m := &dto.Metric{}
+		if pm.Write(m) != nil {
+			continue
+		}
+		if ofTarget(m, conn.Database, "", "") && ofInstance(m, conn.Instance) {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// label returns the value of the label name of m.
+func label(m *dto.Metric, name string) string {
+	for _, lp := range m.GetLabel() {
+		if lp.GetName() == name {
+			return lp.GetValue()
+		}
+	}
+	return ""
+}
+
+// healthVars returns the variables of the health expression of conn from the scrape in m.
+func (m *metricSet) healthVars(conn *Config, up bool) map[string]float64 {
+	vars := map[string]float64{"up": boolValue(up)}
+	if !up {
+		return vars
+	}
+	for _, x := range targetMetrics(m.uptime, conn) {
+		vars["uptime_days"] = x.GetGauge().GetValue()
+	}
+	if sessions := targetMetrics(m.session, conn); len(sessions) > 0 {
+		vars["sessions"], vars["active_sessions"] = 0, 0
+		for _, x := range sessions {
+			vars["sessions"] += x.GetGauge().GetValue()
+			if label(x, "state") == "ACTIVE" {
+				vars["active_sessions"] += x.GetGauge().GetValue()
+			}
+		}
+	}
+	total, used := make(map[string]float64), make(map[string]float64)
+	for _, x := range targetMetrics(m.tablespace, conn) {
+		switch label(x, "type") {
+		case "total":
+			total[label(x, "name")] = x.GetGauge().GetValue()
+		case "used":
+			used[label(x, "name")] = x.GetGauge().GetValue()
+		}
+	}
+	for name, size := range total {
+		if size > 0 {
+			vars["tablespace_used_pct"] = math.Max(vars["tablespace_used_pct"], used[name]/size*100)
+		}
+	}
+	return vars
+}
+
+// healthFuncs returns the functions of the health expression of conn on the scrape in m.
+func (m *metricSet) healthFuncs(conn *Config) map[string]exprFunc {
+	return map[string]exprFunc{
+		// service("APP_SVC") is 1 if the service is active
+		"service": func(args ...string) (float64, error) {
+			if len(args) != 1 {
+				return 0, fmt.Errorf("service(name)")
+			}
+			for _, x := range targetMetrics(m.services, conn) {
+				if label(x, "name") == args[0] {
+					return 1, nil
+				}
+			}
+			return 0, nil
+		},
+		// custom("query", "metric") is the highest value of a custom query metric
+		"custom": func(args ...string) (float64, error) {
+			if len(args) != 2 {
+				return 0, fmt.Errorf("custom(query, metric)")
+			}
+			m.customLok.Lock()
+			vec := m.custom[args[0]]
+			m.customLok.Unlock()
+			if vec == nil {
+				return 0, fmt.Errorf("no rows of custom query %s", args[0])
+			}
+			value, found := math.Inf(-1), false
+			for _, x := range targetMetrics(vec, conn) {
+				if label(x, "metric") == args[1] {
+					value, found = math.Max(value, x.GetGauge().GetValue()), true
+				}
+			}
+			if !found {
+				return 0, fmt.Errorf("no metric %s of custom query %s", args[1], args[0])
+			}
+			return value, nil
+		},
+	}
+}
+
+// evalHealth sets oracledb_healthy of every connection with a health expression, scraped
+// are the connections scraped into m. A target which is down or whose expression fails
+// is unhealthy.
+func (e *Exporter) evalHealth(scraped map[*Config]bool) {
+	cfgLok.Lock()
+	defer cfgLok.Unlock()
+	for i := range config.Cfgs {
+		conn := &config.Cfgs[i]
+		if conn.Health == "" {
+			continue
+		}
+		value, err := evalFuncs(conn.Health, e.healthVars(conn, scraped[conn]), e.healthFuncs(conn))
+		if err != nil {
+			log.Warnf("%s/%s health: %v", conn.Database, conn.Instance, err)
+			value = 0
+		}
+		e.healthy.WithLabelValues(conn.Database, conn.Instance).Set(boolValue(value != 0))
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Heavy moves expensive collectors of a connection, e.g. tablerows or the dictionary scans,
+// out of the scrapes: they run every interval on a connection of their own and the result
+// of their last run is exported by every scrape. The scrapes keep the cheap collectors
+// fresh without running the expensive queries at the scrape interval.
+type Heavy struct {
+	Collectors []string      `yaml:"collectors"`
+	Interval   time.Duration `yaml:"interval,omitempty"` // default 1h
+	Timeout    time.Duration `yaml:"timeout,omitempty"`  // of a run, default the interval
+}
+
+// runs reports whether the collector belongs to the heavy tier, false if h is nil.
+func (h *Heavy) runs(name string) bool {
+	if h == nil {
+		return false
+	}
+	for _, x := range h.Collectors {
+		if x == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Heavy) interval() time.Duration {
+	if h.Interval > 0 {
+		return h.Interval
+	}
+	return time.Hour
+}
+
+func (h *Heavy) timeout() time.Duration {
+	if h.Timeout > 0 {
+		return h.Timeout
+	}
+	return h.interval()
+}
+
+// options returns the options of a run, all listed collectors regardless of the flags.
+func (h *Heavy) options() *scrapeOptions {
+	o := &scrapeOptions{defaultMetrics: true, recovery: true, tableRows: true, tableBytes: true, indexBytes: true,
+		lobBytes: true, objectChanges: true, userStats: true, security: true, heavy: true,
+		collect: make(map[string]bool), optional: make(map[string]bool)}
+	for _, name := range h.Collectors {
+		o.collect[name] = true
+		o.optional[name] = true
+	}
+	return o
+}
+
+// heavyRun is the heavy tier of a target.
+type heavyRun struct {
+	db      *sql.DB // of the heavy tier only
+	set     *metricSet
+	started time.Time
+	running bool
+	seen    bool // target still configured
+}
+
+var (
+	heavyLok  sync.Mutex
+	heavyRuns = make(map[string]*heavyRun) // by heavyKey
+)
+
+func heavyKey(conf *Config) string {
+	return conf.Database + "/" + conf.Instance + "/" + conf.Connection
+}
+
+// runHeavy starts the due runs of the heavy tiers of the connected targets and closes the
+// connections of the targets removed from the config.
+func runHeavy(e *Exporter) {
+	for range time.Tick(10 * time.Second) {
+		cfgLok.Lock()
+		heavyLok.Lock()
+		for _, r := range heavyRuns {
+			r.seen = false
+		}
+		for i := range config.Cfgs {
+			conf := &config.Cfgs[i]
+			if conf.Heavy == nil || len(conf.Heavy.Collectors) == 0 {
+				continue
+			}
+			key := heavyKey(conf)
+			r := heavyRuns[key]
+			if r == nil {
+				r = &heavyRun{}
+				heavyRuns[key] = r
+			}
+			r.seen = true
+			if r.running || conf.db == nil || time.Since(r.started) < conf.Heavy.interval() {
+				continue
+			}
+			r.running, r.started = true, time.Now()
+			// a copy, the status of /targets is of the scrapes
+			c := *conf
+			c.status = nil
+			go e.heavyScrape(&c, r)
+		}
+		for key, r := range heavyRuns {
+			if !r.seen && !r.running {
+				if r.db != nil {
+					r.db.Close()
+				}
+				delete(heavyRuns, key)
+			}
+		}
+		heavyLok.Unlock()
+		cfgLok.Unlock()
+	}
+}
+
+// heavyScrape runs the heavy tier of conf on the connection of r.
+func (e *Exporter) heavyScrape(conf *Config, r *heavyRun) {
+	defer func() {
+		heavyLok.Lock()
+		r.running = false
+		heavyLok.Unlock()
+	}()
+	if !beginScrape() {
+		return
+	}
+	defer scrapes.Done()
+
+	heavyLok.Lock()
+	db := r.db
+	heavyLok.Unlock()
+	if db == nil {
+		dsn, err := conf.dsn()
+		if err != nil {
+			log.Errorln("heavy collectors of", conf.Database+":", err)
+			return
+		}
+		db = openDB(dsn, conf.InitSQL)
+		db.SetMaxOpenConns(1)
+		heavyLok.Lock()
+		r.db = db
+		heavyLok.Unlock()
+	}
+	conf.db = db
+
+	t0 := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), conf.Heavy.timeout())
+	defer cancel()
+	s := e.scrapeSet(conf.Heavy.options())
+	s.scrapeConn(ctx, conf)
+	log.Debugln("heavy collectors of", conf.Database+"/"+conf.Instance, "took", time.Since(t0))
+
+	heavyLok.Lock()
+	r.set = s.metricSet
+	heavyLok.Unlock()
+}
+
+// collectHeavy sends the results of the last runs of the heavy tiers to ch, unless
+// collect[] of opts selects none of their collectors.
+func (e *Exporter) collectHeavy(ch chan<- prometheus.Metric, opts *scrapeOptions) {
+	if opts.heavy {
+		return
+	}
+	cfgLok.Lock()
+	var sets []*metricSet
+	heavyLok.Lock()
+	for i := range config.Cfgs {
+		conf := &config.Cfgs[i]
+		r := heavyRuns[heavyKey(conf)]
+		if conf.Heavy == nil || r == nil || r.set == nil {
+			continue
+		}
+		for _, name := range conf.Heavy.Collectors {
+			if opts.collects(name) {
+				sets = append(sets, r.set)
+				break
+			}
+		}
+	}
+	heavyLok.Unlock()
+	cfgLok.Unlock()
+	for _, set := range sets {
+		set.collectVectors(ch)
+	}
+}
+
+// closeHeavy closes the connections of the heavy tiers.
+func closeHeavy() {
+	heavyLok.Lock()
+	defer heavyLok.Unlock()
+	for key, r := range heavyRuns {
+		if r.db != nil {
+			r.db.Close()
+		}
+		delete(heavyRuns, key)
+	}
+}
+
+// scrapeHeavyNow runs the heavy tier of conf on its connection for POST /scrapeNow and
+// makes the result the one exported by the scrapes. It returns nil if conf has no heavy
+// tier or a run of it is already going on.
+func (e *Exporter) scrapeHeavyNow(ctx context.Context, conf *Config) *metricSet {
+	if conf.Heavy == nil || len(conf.Heavy.Collectors) == 0 {
+		return nil
+	}
+	key := heavyKey(conf)
+	heavyLok.Lock()
+	r := heavyRuns[key]
+	if r == nil {
+		r = &heavyRun{seen: true}
+		heavyRuns[key] = r
+	}
+	if r.running {
+		heavyLok.Unlock()
+		return nil
+	}
+	r.running, r.started = true, time.Now()
+	heavyLok.Unlock()
+	defer func() {
+		heavyLok.Lock()
+		r.running = false
+		heavyLok.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, conf.Heavy.timeout())
+	defer cancel()
+	s := e.scrapeSet(conf.Heavy.options())
+	s.scrapeConn(ctx, conf)
+
+	heavyLok.Lock()
+	r.set = s.metricSet
+	heavyLok.Unlock()
+	return s.metricSet
+}
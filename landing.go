@@ -0,0 +1,87 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// landingData is the content of the landing page.
+type landingData struct {
+	Version    string
+	BuildDate  string
+	MetricPath string
+	Admin      string
+	Collectors []string
+	Targets    []targetInfo
+}
+
+// enabledCollectors returns the collectors run by scrapes without request parameters;
+// watch, oem and aq run for the connections configuring them.
+func (o *scrapeOptions) enabledCollectors() []string {
+	var names []string
+	if o.recovery {
+		names = append(names, "recovery")
+	}
+	if o.defaultMetrics {
+		names = append(names, defaultCollectors...)
+	}
+	for _, name := range optionalCollectors {
+		if o.optional[name] {
+			names = append(names, name)
+		}
+	}
+	names = append(names, "custom")
+	for _, c := range []struct {
+		on   bool
+		name string
+	}{
+		{o.tableRows, "tablerows"}, {o.tableBytes, "tablebytes"}, {o.indexBytes, "indexbytes"},
+		{o.lobBytes, "lobbytes"}, {o.objectChanges, "objectchanges"}, {o.userStats, "userstats"},
+		{o.security, "security"},
+	} {
+		if c.on {
+			names = append(names, c.name)
+		}
+	}
+	return names
+}
+
+var landingPage = template.Must(template.New("landing").Funcs(pageFuncs).Parse(`<html>
+<head><title>Prometheus Oracle exporter</title>
+<style>td, th { padding: 2px 8px; text-align: left; } tr.down { background: #fdd; }</style></head>
+<body>
+<h1>Prometheus Oracle exporter</h1>
+<p>Version {{.Version}}{{if .BuildDate}}, built {{.BuildDate}}{{end}}</p>
+<p><a href="{{.MetricPath}}">Metrics</a></p>
+<p>Metrics with
+<a href="{{.MetricPath}}?tablerows=true">tablerows</a>,
+<a href="{{.MetricPath}}?tablebytes=true">tablebytes</a>,
+<a href="{{.MetricPath}}?indexbytes=true">indexbytes</a>,
+<a href="{{.MetricPath}}?lobbytes=true">lobbytes</a>,
+<a href="{{.MetricPath}}?recovery=true">recovery</a>,
+<a href="{{.MetricPath}}?objectchanges=true">objectchanges</a></p>
+<p>Enabled collectors: {{range $i, $c := .Collectors}}{{if $i}}, {{end}}{{$c}}{{end}}</p>
+<h2>Targets</h2>
+<table>
+<tr><th>Database</th><th>Instance</th><th>Up</th><th>Last scrape</th><th>Seconds</th><th>Last error</th></tr>
+{{range .Targets}}<tr{{if not .Up}} class="down"{{end}}><td><a href="{{$.MetricPath}}/{{.Database}}">{{.Database}}</a></td><td>{{.Instance}}</td><td>{{.Up}}</td>
+<td>{{time .LastScrape}}</td><td>{{printf "%.3f" .ScrapeSeconds}}</td><td>{{time .LastErrorTime}} {{.LastError}}</td></tr>
+{{end}}</table>
+<p><a href="{{.Admin}}/targets?format=html">Details of the targets</a></p>
+</body>
+</html>`))
+
+// LandingHandler shows the version, the enabled collectors and the targets with their
+// up status, read at every request.
+func LandingHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	landingPage.Execute(w, landingData{
+		Version:    Version,
+		BuildDate:  BuildDate,
+		MetricPath: strings.TrimRight(*metricPath, "/"),
+		Admin:      strings.TrimRight(*adminPrefix, "/"),
+		Collectors: flagOptions().enabledCollectors(),
+		Targets:    targetInfos(),
+	})
+}
@@ -3,13 +3,14 @@ package main
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
-	_ "net/http/pprof"
+	"net/http/pprof"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -20,6 +21,9 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
 	_ "github.com/sijms/go-ora/v2"
 	log "github.com/sirupsen/logrus"
 )
@@ -31,76 +35,229 @@ const (
 )
 
 // Exporter collects Oracle DB metrics. It implements prometheus.Collector.
+// Each scrape fills a fresh metricSet, which replaces the last complete one when the
+// scrape is done, so a concurrent exposition never sees the vectors half filled.
 type Exporter struct {
+	*exporterState
+	*metricSet
+}
+
+// exporterState is shared by all scrapes: counters, state kept between scrapes and options.
+type exporterState struct {
 	duration, error prometheus.Gauge
 	totalScrapes    prometheus.Counter
 	scrapeErrors    *prometheus.CounterVec
-	session         *prometheus.GaugeVec
-	sysstat         *prometheus.GaugeVec
-	waitclass       *prometheus.GaugeVec
-	sysmetric       *prometheus.GaugeVec
-	interconnect    *prometheus.GaugeVec
-	uptime          *prometheus.GaugeVec
+	restarts        *prometheus.CounterVec
+	startups        map[string]string
+	startupLok      sync.Mutex
 	up              *prometheus.GaugeVec
-	tablespace      *prometheus.GaugeVec
-	recovery        *prometheus.GaugeVec
-	redo            *prometheus.GaugeVec
-	cache           *prometheus.GaugeVec
-	alertlog        *prometheus.GaugeVec
-	alertdate       *prometheus.GaugeVec
-	services        *prometheus.GaugeVec
-	parameter       *prometheus.GaugeVec
-	//query           *prometheus.GaugeVec
+	connRetries     *prometheus.CounterVec
+	driverInfo      *prometheus.GaugeVec
+	identFallbacks  *prometheus.CounterVec
+	extensions      *prometheus.CounterVec
+	fileBytes       map[string]float64
+	fileLok         sync.Mutex
+	paramchanges    *prometheus.CounterVec
+	paramValues     map[string]map[string]string
+	paramLok        sync.Mutex
+	skipWarned      sync.Map
+	used_times      *prometheus.GaugeVec
+	pagers          map[string]*keysetPager
+	pagerLok        sync.Mutex
+	disabled        map[string]int
+	disabledLok     sync.Mutex
+	collectorOff    *prometheus.GaugeVec
+	scrapeOpts      *prometheus.Desc
+	customCount     *prometheus.GaugeVec
+	seriesCapped    *prometheus.CounterVec
+	configGen       prometheus.Counter
+	sharedScrapes   prometheus.Counter
+	durations       *prometheus.SummaryVec
+	guard           *scrapeGuard // nil without -web.max-concurrent-scrapes
+	alerts          *alertStore
+	last            *metricSet // of the last full scrape, see scrapeOptions.full
+	prev            *metricSet // the one before last, for /debug/diff
+	lastLok         sync.Mutex
+}
+
+// metricSet are the metric vectors filled by one scrape.
+type metricSet struct {
+	session      *prometheus.GaugeVec
+	sysstat      *prometheus.GaugeVec
+	waitclass    *prometheus.GaugeVec
+	sysmetric    *prometheus.GaugeVec
+	aas          *prometheus.GaugeVec
+	interconnect *prometheus.GaugeVec
+	uptime       *prometheus.GaugeVec
+	startup      *prometheus.GaugeVec
+	tablespace   *prometheus.GaugeVec
+	nearmaxsize  *prometheus.GaugeVec
+	recovery     *prometheus.GaugeVec
+	redo         *prometheus.GaugeVec
+	cache        *prometheus.GaugeVec
+	alertlog     *prometheus.GaugeVec
+	alertdate    *prometheus.GaugeVec
+	services     *prometheus.GaugeVec
+	parameter    *prometheus.GaugeVec
+	component    *prometheus.GaugeVec
+	directory    *prometheus.GaugeVec
+	exttables    *prometheus.GaugeVec
+	patch        *prometheus.GaugeVec
+	patchdate    *prometheus.GaugeVec
+	paramstate   *prometheus.GaugeVec
+	//query    *prometheus.GaugeVec
 	asmspace   *prometheus.GaugeVec
+	location   *prometheus.GaugeVec
+	healthy    *prometheus.GaugeVec
+	locFree    *prometheus.GaugeVec
+	tempundo   *prometheus.GaugeVec
+	tempseg    *prometheus.GaugeVec
+	cursors    *prometheus.GaugeVec
+	clockskew  *prometheus.GaugeVec
+	pdbOpen    *prometheus.GaugeVec
+	pdbViolate *prometheus.GaugeVec
+	dpJobs     *prometheus.GaugeVec
+	dpJobAge   *prometheus.GaugeVec
+	rmanJobs   *prometheus.GaugeVec
+	rmanAge    *prometheus.GaugeVec
+	dbSize     *prometheus.GaugeVec
+	awrRetain  *prometheus.GaugeVec
+	awrSnapInt *prometheus.GaugeVec
+	awrSnapAge *prometheus.GaugeVec
+	sgaBytes   *prometheus.GaugeVec
+	sgaComp    *prometheus.GaugeVec
+	pgaBytes   *prometheus.GaugeVec
+	dgLag      *prometheus.GaugeVec
+	dgRate     *prometheus.GaugeVec
+	dgDestLag  *prometheus.GaugeVec
+	dgStatus   *prometheus.GaugeVec
+	dgMember   *prometheus.GaugeVec
+	fsfoStatus *prometheus.GaugeVec
+	fsfoObsrv  *prometheus.GaugeVec
+	archDest   *prometheus.GaugeVec
+	archErr    *prometheus.GaugeVec
+	archGap    *prometheus.GaugeVec
+	blocking   *prometheus.GaugeVec
+	blockMax   *prometheus.GaugeVec
+	blockers   *prometheus.GaugeVec
+	enqReqs    *counterVec
+	enqWaits   *counterVec
+	enqWaitSec *counterVec
+	longopPct  *prometheus.GaugeVec
+	longopTime *prometheus.GaugeVec
+	longopLeft *prometheus.GaugeVec
+	undoBytes  *prometheus.GaugeVec
+	undoSnapTO *prometheus.GaugeVec
+	undoMaxQry *prometheus.GaugeVec
+	tempBytes  *prometheus.GaugeVec
+	tempUsers  *prometheus.GaugeVec
+	resLimit   *prometheus.GaugeVec
+	asmDiskOps *counterVec
+	asmDiskErr *counterVec
+	asmDiskUp  *prometheus.GaugeVec
+	asmDgBytes *prometheus.GaugeVec
+	asmRebPwr  *prometheus.GaugeVec
+	asmRebLeft *prometheus.GaugeVec
 	tablerows  *prometheus.GaugeVec
 	tablebytes *prometheus.GaugeVec
 	indexbytes *prometheus.GaugeVec
 	lobbytes   *prometheus.GaugeVec
-	lastIp     string
-	vTabRows   bool
-	vTabBytes  bool
-	vIndBytes  bool
-	vLobBytes  bool
-	vRecovery  bool
+	objchanges *prometheus.GaugeVec
+	userstat   *prometheus.GaugeVec
+	watched    *prometheus.GaugeVec
+	failLogons *prometheus.GaugeVec
+	debugTime  *prometheus.GaugeVec
+	debugRows  *prometheus.GaugeVec
+	debugSql   *prometheus.GaugeVec
+	lockouts   *prometheus.GaugeVec
+	watchWait  *prometheus.GaugeVec
+	oemUp      *prometheus.GaugeVec
+	oemMetric  *prometheus.GaugeVec
+	aqMessages *prometheus.GaugeVec
+	aqAge      *prometheus.GaugeVec
+	skippedCol *prometheus.GaugeVec
 	custom     map[string]*prometheus.GaugeVec
-	used_times *prometheus.GaugeVec
-	gctx       context.Context
+	series     map[string]map[uint64]prometheus.Labels // see setCustom
+	customLok  sync.Mutex
+	opts       *scrapeOptions // of the scrape filling the set
 }
 
 var (
-	// Version will be set at build time.
+	// Version and BuildDate will be set at build time, e.g. -ldflags "-X main.BuildDate=$(date -u +%F)".
 	Version       = "1.1.5"
+	BuildDate     = ""
 	listenAddress = flag.String("web.listen-address", ":9161", "Address to listen on for web interface and telemetry.")
 	metricPath    = flag.String("web.telemetry-path", "/metrics", "Path under which to expose metrics.")
+	adminPrefix   = flag.String("web.admin-prefix", "", "Path prefix of the admin routes showConfig, reloadConfig, scrapeNow, getTimeout and setTimeout, e.g. /admin")
+	adminToken    = flag.String("web.admin-token", os.Getenv("ORACLE_EXPORTER_ADMIN_TOKEN"), "Bearer token required by the POST admin routes config, reloadConfig and setTimeout and the profiler, disabled if empty (env ORACLE_EXPORTER_ADMIN_TOKEN)")
+	adminUsers    = flag.String("web.admin-users", "", "Comma separated basic_auth_users of -web.config.file allowed on the POST admin routes and the profiler, the other users may only read")
+	webConfigFile = flag.String("web.config.file", "", "Web configuration file in the format of the Prometheus exporter-toolkit, its tls_server_config enables HTTPS")
+	maxScrapes    = flag.Int("web.max-concurrent-scrapes", 0, "Scrapes running at the same time, a request waiting for a slot gets the result of a scrape with the same parameters finished meanwhile (0 unlimited)")
+	shutdownWait  = flag.Duration("web.shutdown-timeout", 30*time.Second, "Wait this long for running scrapes on SIGTERM or SIGINT before the database connections are closed")
+	enablePprof   = flag.Bool("web.pprof", false, "Serve the Go profiler under <web.admin-prefix>/debug/pprof/")
 	pMetrics      = flag.Bool("defaultmetrics", true, "Expose standard metrics")
+	optCollect    = flag.String("collectors.optional", "", "Comma separated optional collectors run by every scrape besides the standard metrics: parameterchanges, pdbs, jobs, dbsize, awr, memory, dataguard, dgbroker, archivedest, blocking, enqueue, longops, undo, temp, resource")
 	pTabRows      = flag.Bool("tablerows", false, "Expose Table rows (CAN TAKE VERY LONG)")
 	pTabBytes     = flag.Bool("tablebytes", false, "Expose Table size (CAN TAKE VERY LONG)")
 	pIndBytes     = flag.Bool("indexbytes", false, "Expose Index size for any Table (CAN TAKE VERY LONG)")
 	pLobBytes     = flag.Bool("lobbytes", false, "Expose Lobs size for any Table (CAN TAKE VERY LONG)")
 	pRecovery     = flag.Bool("recovery", false, "Expose Recovery percentage usage of FRA (CAN TAKE VERY LONG)")
+	pObjChange    = flag.Bool("objectchanges", false, "Expose count of objects changed by DDL per owner")
+	objChangeHrs  = flag.Int("objectchanges.hours", 24, "Lookback window in hours for objectchanges")
+	pUserStats    = flag.Bool("userstats", false, "Expose v$sesstat statistics summed per username")
+	userStatList  = flag.String("userstats.stats", "CPU used by this session,session logical reads,session pga memory", "Comma separated v$statname names for userstats")
+	pSecurity     = flag.Bool("security", false, "Expose failed logons from the audit trail and account lockouts")
+	securityHrs   = flag.Int("security.hours", 1, "Lookback window in hours for security")
+	securityTop   = flag.Int("security.top", 10, "Export only the users with the most failed logons for security")
+	blockingTop   = flag.Int("blocking.top", 0, "Export the blockers of the most sessions with their sid and username for blocking (0 none)")
+	userStatTop   = flag.Int("userstats.top", 10, "Export only the users with the highest values per statistic for userstats")
 	configFile    = flag.String("configfile", "oracle.conf", "ConfigurationFile in YAML format.")
-	logFile       = flag.String("logfile", "exporter.log", "Logfile for parsed Oracle Alerts.")
-	accessFile    = flag.String("accessfile", "access.conf", "Last access for parsed Oracle Alerts.")
+	logFile       = flag.String("logfile", "exporter.log", "Logfile of the exporter, relative to the directory of the executable")
+	accessFile    = flag.String("accessfile", "", "Deprecated and ignored, the alert log state is kept in -alertlog.state")
+	alertState    = flag.String("alertlog.state", "alertlog.jsonl", "JSON lines file of the positions read and the ORA errors found in the alert logs, relative to the directory of the executable")
+	alertWindow   = flag.Duration("alertlog.window", 24*time.Hour, "ORA errors of the alert logs are counted over this time")
+	alertRetain   = flag.Duration("alertlog.retention", 7*24*time.Hour, "Keep the ORA errors found in the alert logs this long for /alerts")
+	connRetryMax  = flag.Int("connect.retries", 2, "Retries of a connect failing with a transient network error (ORA-12170, ORA-12541, refused or timed out)")
+	connBackoff   = flag.Duration("connect.backoff", 500*time.Millisecond, "Wait before the first connect retry, doubled for every further retry")
 	timeout       = flag.Int("timeout", 5, "Collect Scrape All Metrics total time (db.Ping st.Query ...)")
-	testconn      = flag.Bool("testconn", false, "just test connect time")
+	timeoutOffset = flag.Duration("timeout-offset", 500*time.Millisecond, "Subtracted from the X-Prometheus-Scrape-Timeout-Seconds header of a scrape, which replaces -timeout, so the response arrives before Prometheus gives up")
+	maxsizePct    = flag.Float64("datafiles.maxsize-pct", 10, "Count autoextensible datafiles with less than this percent left to their maxbytes")
+	durWindow     = flag.Duration("collector.duration-window", time.Hour, "Sliding window of the collector duration percentiles of oracledb_exporter_collector_duration_seconds and /status")
+	disableAfter  = flag.Int("disable-after", 3, "Stop running a collector for a target after this many consecutive ORA-00942 errors until the config is reloaded (0 never)")
+	maxSeries     = flag.Int("custom.max-series", 0, "Drop a custom query of a target from the scrape when it returns more distinct label sets than this, protecting against unbounded label cardinality (0 unlimited)")
+	pageSize      = flag.Int("pagesize", 1000, "Rows per page of tablerows/tablebytes/indexbytes/lobbytes scans, resumed on the next scrape after a timeout")
+	testconn      = flag.Bool("testconn", false, "Test the connect and an identity query of every connection, print the timings and exit non-zero if one fails (same as the testconn subcommand)")
+	testFormat    = flag.String("format", "text", "Output format of testconn: text or json")
+	encryptPass   = flag.Bool("encrypt-password", false, "Read a password from stdin, print its password_encrypted value for the config and exit")
+	masterKeyFile = flag.String("master-key.file", "", "File of the AES key (16, 24 or 32 bytes, raw or base64) decrypting password_encrypted")
+	masterKeyKms  = flag.String("master-key.kms", "", "File of the AES key of password_encrypted encrypted with AWS KMS, decrypted with the aws CLI at load time")
+	checkCfg      = flag.Bool("check-config", false, "Check the config file without connecting to any database, print the problems and exit non-zero if there are any")
+	grants        = flag.Bool("grants", false, "Print the CREATE USER/GRANT script for the enabled collectors and custom queries and exit")
+	rules         = flag.Bool("rules", false, "Print Prometheus alerting rules for the enabled collectors and the config and exit (same as the rules subcommand)")
+	rulesTsPct    = flag.Float64("rules.tablespace-pct", 90, "Percent used of a tablespace or ASM diskgroup alerted by -rules")
+	rulesFraPct   = flag.Float64("rules.fra-pct", 85, "Percent used and not reclaimable of the recovery area alerted by -rules")
+	rulesLag      = flag.Duration("rules.lag", 5*time.Minute, "Apply lag of a standby database alerted by -rules")
+	rulesBlock    = flag.Duration("rules.blocking", 5*time.Minute, "Wait of a blocked session alerted by -rules")
+	rulesResPct   = flag.Float64("rules.resource-pct", 90, "Percent of the limit of processes, sessions or transactions alerted by -rules")
+	rulesFor      = flag.Duration("rules.for", 5*time.Minute, "Time a condition has to hold before the alerts of -rules fire")
+	grantsUser    = flag.String("grants.user", "prometheus", "Monitoring account name used by -grants")
+	grantsCdb     = flag.Bool("grants.cdb", false, "Create a common user for a 12c+ multitenant container database with -grants")
 	openfiles     = flag.Int("openfiles", 0, "open files")
-	landingPage   = []byte(`<html>
-                          <head><title>Prometheus Oracle exporter</title></head>
-                          <body>
-                            <h1>Prometheus Oracle exporter</h1><p>
-                            <a href='` + *metricPath + `'>Metrics</a></p>
-                            <a href='` + *metricPath + `?tablerows=true'>Metrics with tablerows</a></p>
-                            <a href='` + *metricPath + `?tablebytes=true'>Metrics with tablebytes</a></p>
-                            <a href='` + *metricPath + `?indexbytes=true'>Metrics with indexbytes</a></p>
-                            <a href='` + *metricPath + `?lobbytes=true'>Metrics with lobbytes</a></p>
-                            <a href='` + *metricPath + `?recovery=true'>Metrics with recovery</a></p>
-                          </body>
-                          </html>`)
+	nlsNumeric    = flag.String("nls.numeric-characters", "", "NLS_NUMERIC_CHARACTERS set on every session, e.g. '.,' so to_char of numbers in custom queries is parsed the same everywhere (empty keeps the database default)")
+	nlsDate       = flag.String("nls.date-format", "", "NLS_DATE_FORMAT set on every session, e.g. 'YYYY-MM-DD HH24:MI:SS' (empty keeps the database default)")
+	labelMaxLen   = flag.Int("label.max-length", 0, "Shorten longer label values, e.g. SQL text of custom queries, to this many bytes ending in ~ and a hash of the value, at least 9 (0 unlimited)")
+	namespaceFlag = flag.String("namespace", namespace, "Prefix of all metric names, e.g. oracle for dashboards of a legacy exporter; the namespace of a connection overrides it for its target")
+	globalLabels  = flag.String("labels", os.Getenv("ORACLE_EXPORTER_LABELS"), "Labels added to every exported series, e.g. region=eu1,dc=fra, not the name of a label of a metric (env ORACLE_EXPORTER_LABELS)")
+	textFile      = flag.String("textfile", "", "Write metrics to this file (\"-\" for stdout) every textfile.interval instead of serving HTTP.")
+	tnsAdmin      = flag.String("tnsadmin", os.Getenv("TNS_ADMIN"), "Directory of the tnsnames.ora resolving user/password@alias connections (env TNS_ADMIN, else $ORACLE_HOME/network/admin)")
+	secretRefresh = flag.Duration("secrets.refresh", time.Hour, "Fetch credentials of vault_path/aws_secret/gcp_secret/cyberark again after this time, unless the secret has a lease (0 never)")
+	targetsPoll   = flag.Duration("targets.interval", 30*time.Second, "Interval between checks of the targets_file and srv_targets of the config for changed connections")
+	textInterval  = flag.Duration("textfile.interval", time.Minute, "Interval between writes in textfile mode.")
 )
 
 // NewExporter returns a new Oracle DB exporter for the provided DSN.
 func NewExporter() *Exporter {
-	e := Exporter{
+	e := Exporter{exporterState: &exporterState{
 		duration: prometheus.NewGauge(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Subsystem: exporter,
@@ -125,11 +282,134 @@ func NewExporter() *Exporter {
 			Name:      "last_scrape_error",
 			Help:      "Whether the last scrape of metrics from Oracle DB resulted in an error (1 for error, 0 for success).",
 		}),
+		restarts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "instance_restarts_total",
+			Help:      "Number of Instance restarts detected by a changed startup_time between scrapes.",
+		}, []string{"database", "dbinstance"}),
+		startups: make(map[string]string),
+		connRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: exporter,
+			Name:      "connect_retries_total",
+			Help:      "Connect attempts repeated after a transient network error (ORA-12170, ORA-12541).",
+		}, []string{"database", "dbinstance"}),
+		identFallbacks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: exporter,
+			Name:      "identity_fallbacks_total",
+			Help:      "Connects which could not read the database or instance name from the view and fell back to sys_context.",
+		}, []string{"database", "dbinstance", "view"}),
+		driverInfo: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: exporter,
+			Name:      "driver_info",
+			Help:      "Always 1, labels show the driver version and what it negotiated with the server on the last connect.",
+		}, []string{"database", "dbinstance", "driver", "driver_version", "protocol_version", "ttc_version", "server_version"}),
+		extensions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "datafile_extensions_total",
+			Help:      "Number of datafile size increases (autoextend or resize) seen between scrapes (dba_data_files).",
+		}, []string{"database", "dbinstance", "tablespace"}),
+		fileBytes: make(map[string]float64),
+		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "up",
+			Help:      "Whether the Oracle server is up.",
+		}, []string{"database", "dbinstance", "hostname"}),
+		paramchanges: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "parameter_changes_total",
+			Help:      "Number of value changes of a Configuration Parameter seen between scrapes (v$parameter).",
+		}, []string{"database", "dbinstance", "name"}),
+		paramValues: make(map[string]map[string]string),
+		// query: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		// 	Namespace: namespace,
+		// 	Name:      "query",
+		// 	Help:      "Self defined Queries from Configuration File.",
+		// }, []string{"database", "dbinstance", "name", "column", "row"}),
+		customCount: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: exporter,
+			Name:      "custom_queries",
+			Help:      "Number of custom queries loaded from the config file per target.",
+		}, []string{"database", "dbinstance"}),
+		seriesCapped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: exporter,
+			Name:      "custom_series_capped_total",
+			Help:      "Scrapes dropping a custom query of a target because it returned more than custom.max-series label sets.",
+		}, []string{"database", "dbinstance", "query"}),
+		configGen: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: exporter,
+			Name:      "config_generations_total",
+			Help:      "Times the config file was (re)loaded.",
+		}),
+		sharedScrapes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: exporter,
+			Name:      "scrapes_shared_total",
+			Help:      "Requests answered with the result of a concurrent scrape with the same parameters, by web.max-concurrent-scrapes.",
+		}),
+		durations: prometheus.NewSummaryVec(prometheus.SummaryOpts{
+			Namespace:  namespace,
+			Subsystem:  exporter,
+			Name:       "collector_duration_seconds",
+			Help:       "Percentiles of the durations of each collector per target over the last collector.duration-window.",
+			Objectives: map[float64]float64{0.5: 0.05, 0.95: 0.01, 0.99: 0.001},
+			MaxAge:     *durWindow,
+		}, []string{"database", "dbinstance", "collector"}),
+		pagers:   make(map[string]*keysetPager),
+		disabled: make(map[string]int),
+		collectorOff: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: exporter,
+			Name:      "collector_disabled",
+			Help:      "Collectors disabled for a target after repeated ORA-00942 (table or view does not exist).",
+		}, []string{"database", "dbinstance", "collector"}),
+		scrapeOpts: prometheus.NewDesc(prometheus.BuildFQName(namespace, exporter, "scrape_options"),
+			"Always 1, labels show the collector toggles (flags and URL parameters) applied to this scrape.",
+			[]string{"defaultmetrics", "recovery", "tablerows", "tablebytes", "indexbytes", "lobbytes", "objectchanges"}, nil),
+		used_times: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "collect_used_times",
+				Help:      "this prometheus oracle exporter used time",
+			},
+			[]string{"ipport", "svname", "column"},
+		),
+	}, metricSet: newMetricSet()}
+	e.opts = flagOptions()
+	if *maxScrapes > 0 {
+		e.guard = newScrapeGuard(*maxScrapes)
+	}
+	if *accessFile != "" {
+		log.Warnln("-accessfile is deprecated and ignored, the alert log state is kept in", *alertState)
+	}
+	if alerts, err := loadAlertStore(*alertState); err != nil {
+		log.Errorln("alert log state:", err)
+	} else {
+		e.alerts = alerts
+	}
+
+	addCustomsql(&e)
+	return &e
+}
+
+// newMetricSet returns empty vectors for a scrape.
+func newMetricSet() *metricSet {
+	return &metricSet{
 		sysmetric: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Name:      "sysmetric",
 			Help:      "Gauge metric with read/write pysical IOPs/bytes (v$sysmetric).",
 		}, []string{"database", "dbinstance", "type"}),
+		aas: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "average_active_sessions",
+			Help:      "Gauge metric with Average Active Sessions over the last minute (v$sysmetric).",
+		}, []string{"database", "dbinstance"}),
 		waitclass: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Name:      "waitclass",
@@ -150,11 +430,21 @@ func NewExporter() *Exporter {
 			Name:      "uptime",
 			Help:      "Gauge metric with uptime in days of the Instance.",
 		}, []string{"database", "dbinstance", "hostname"}),
+		startup: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "instance_startup_unix_seconds",
+			Help:      "Unixtime of the Instance startup (v$instance).",
+		}, []string{"database", "dbinstance"}),
 		tablespace: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Name:      "tablespace",
 			Help:      "Gauge metric with total/free size of the Tablespaces.",
 		}, []string{"database", "dbinstance", "type", "name", "contents", "autoextend"}),
+		nearmaxsize: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "datafiles_near_maxsize",
+			Help:      "Gauge metric with number of autoextensible datafiles within datafiles.maxsize-pct of their maxbytes (dba_data_files).",
+		}, []string{"database", "dbinstance", "tablespace"}),
 		interconnect: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Name:      "interconnect",
@@ -175,15 +465,10 @@ func NewExporter() *Exporter {
 			Name:      "cachehitratio",
 			Help:      "Gauge metric witch Cache hit ratios (v$sysmetric).",
 		}, []string{"database", "dbinstance", "type"}),
-		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "up",
-			Help:      "Whether the Oracle server is up.",
-		}, []string{"database", "dbinstance", "hostname"}),
 		alertlog: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Name:      "error",
-			Help:      "Oracle Errors occured during configured interval.",
+			Help:      "ORA errors in the alert log during the last -alertlog.window.",
 		}, []string{"database", "dbinstance", "code", "description", "ignore"}),
 		alertdate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: namespace,
@@ -200,16 +485,307 @@ func NewExporter() *Exporter {
 			Name:      "parameter",
 			Help:      "oracle Configuration Parameters (v$parameter).",
 		}, []string{"database", "dbinstance", "name"}),
-		// query: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		// 	Namespace: namespace,
-		// 	Name:      "query",
-		// 	Help:      "Self defined Queries from Configuration File.",
-		// }, []string{"database", "dbinstance", "name", "column", "row"}),
+		component: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "component",
+			Help:      "Installed database components, 1 if VALID (dba_registry).",
+		}, []string{"database", "dbinstance", "comp_id", "comp_name", "version", "status"}),
+		directory: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "directory_info",
+			Help:      "Directory objects with their filesystem path, always 1 (dba_directories).",
+		}, []string{"database", "dbinstance", "owner", "directory_name", "directory_path"}),
+		exttables: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "external_tables",
+			Help:      "Number of external tables per owner and default directory (dba_external_tables).",
+		}, []string{"database", "dbinstance", "owner", "directory_name"}),
+		patch: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "patch_info",
+			Help:      "Latest SQL patch (RU/PSU) action of the database (dba_registry_sqlpatch).",
+		}, []string{"database", "dbinstance", "patch_id", "description", "action", "status", "applied"}),
+		patchdate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "patch_applied_unix_seconds",
+			Help:      "Unixtime of the latest SQL patch action (dba_registry_sqlpatch).",
+		}, []string{"database", "dbinstance"}),
+		paramstate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "parameter_state",
+			Help:      "Non default or modified Configuration Parameters with their isdefault/ismodified flags (v$parameter).",
+		}, []string{"database", "dbinstance", "name", "isdefault", "ismodified"}),
+		healthy: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "healthy",
+			Help:      "1 if the health expression of the connection is true in this scrape, 0 if it is false, fails or the target is down.",
+		}, []string{"database", "dbinstance"}),
+		location: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "datafile_location_bytes",
+			Help:      "Gauge metric with the bytes of the data and temp files per directory or ASM diskgroup, allocated and maximum with autoextend.",
+		}, []string{"database", "dbinstance", "location", "type"}),
+		locFree: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "datafile_location_free_bytes",
+			Help:      "Gauge metric with the usable free bytes of the ASM diskgroups holding data or temp files (v$asm_diskgroup_stat), filesystems are not visible to SQL.",
+		}, []string{"database", "dbinstance", "location"}),
 		asmspace: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Name:      "asmspace",
 			Help:      "Gauge metric with total/free size of the ASM Diskgroups.",
 		}, []string{"database", "dbinstance", "type", "name"}),
+		tempundo: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "tempundo",
+			Help:      "Gauge metric with temporary undo of the last 10 minute interval, 12c+ (v$tempundostat).",
+		}, []string{"database", "dbinstance", "type"}),
+		tempseg: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "temp_segment_bytes",
+			Help:      "Gauge metric with bytes of temporary segments in use per segment type, e.g. DATA/INDEX of global temporary tables and UNDO (v$tempseg_usage).",
+		}, []string{"database", "dbinstance", "tablespace", "segtype"}),
+		cursors: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: exporter,
+			Name:      "open_cursors",
+			Help:      "Cursors currently open by the sessions of the exporter's database user (v$sesstat).",
+		}, []string{"database", "dbinstance"}),
+		clockskew: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "clock_skew_seconds",
+			Help:      "Database clock minus exporter host clock, systimestamp compares UTC, sysdate compares the local wall clocks and includes a time zone difference.",
+		}, []string{"database", "dbinstance", "clock"}),
+		pdbOpen: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pdb_open_mode",
+			Help:      "Open mode of each PDB, 1 if open READ WRITE or READ ONLY and not restricted, 12c+ (v$pdbs).",
+		}, []string{"database", "dbinstance", "pdb", "con_id", "open_mode", "restricted"}),
+		pdbViolate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pdb_plug_in_violations",
+			Help:      "Unresolved plug in violations per PDB and type ERROR or WARNING, e.g. after patching, 12c+ (pdb_plug_in_violations).",
+		}, []string{"database", "dbinstance", "pdb", "con_id", "type"}),
+		dpJobs: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "datapump_jobs",
+			Help:      "Data Pump jobs per state, NOT RUNNING are stopped or failed jobs left with their master table (dba_datapump_jobs).",
+		}, []string{"database", "dbinstance", "state"}),
+		dpJobAge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "datapump_job_age_seconds",
+			Help:      "Seconds since each Data Pump job was created, by its master table (dba_datapump_jobs, dba_objects).",
+		}, []string{"database", "dbinstance", "owner", "job_name", "operation", "state"}),
+		rmanJobs: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "rman_jobs",
+			Help:      "RMAN jobs running or started in the last 24 hours per operation and status (v$rman_status).",
+		}, []string{"database", "dbinstance", "operation", "status"}),
+		rmanAge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "rman_running_seconds",
+			Help:      "Seconds since the oldest running RMAN job per operation was started (v$rman_status).",
+		}, []string{"database", "dbinstance", "operation"}),
+		dbSize: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "database_size_bytes",
+			Help:      "Size of the database, type allocated by the datafiles, used by segments, temp files and redo logs (dba_data_files, dba_segments, dba_temp_files, v$log).",
+		}, []string{"database", "dbinstance", "type"}),
+		awrRetain: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "awr",
+			Name:      "retention_seconds",
+			Help:      "Retention of the AWR snapshots, only with the Diagnostics Pack enabled by control_management_pack_access (dba_hist_wr_control).",
+		}, []string{"database", "dbinstance"}),
+		awrSnapInt: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "awr",
+			Name:      "snapshot_interval_seconds",
+			Help:      "Interval of the AWR snapshots, only with the Diagnostics Pack enabled by control_management_pack_access (dba_hist_wr_control).",
+		}, []string{"database", "dbinstance"}),
+		awrSnapAge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "awr",
+			Name:      "last_snapshot_age_seconds",
+			Help:      "Seconds since the end of the last AWR snapshot of the instance, only with the Diagnostics Pack enabled by control_management_pack_access (dba_hist_snapshot).",
+		}, []string{"database", "dbinstance"}),
+		sgaBytes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "sga_bytes",
+			Help:      "Gauge metric with the sizes of the SGA, e.g. fixed_sga_size, redo_buffers, maximum_sga_size and free_sga_memory_available (v$sgainfo).",
+		}, []string{"database", "dbinstance", "component"}),
+		sgaComp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "sga_component_bytes",
+			Help:      "Gauge metric with the current, min and max size of the dynamic SGA components like the shared pool and the buffer cache (v$sga_dynamic_components).",
+		}, []string{"database", "dbinstance", "component", "type"}),
+		pgaBytes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pga_bytes",
+			Help:      "Gauge metric with the PGA statistics in bytes, e.g. aggregate_pga_target_parameter, total_pga_allocated and total_pga_inuse (v$pgastat).",
+		}, []string{"database", "dbinstance", "type"}),
+		dgLag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "dataguard",
+			Name:      "lag_seconds",
+			Help:      "Transport and apply lag of a standby database in seconds (v$dataguard_stats).",
+		}, []string{"database", "dbinstance", "type"}),
+		dgRate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "dataguard",
+			Name:      "apply_rate_bytes_per_second",
+			Help:      "Active and average redo apply rate of the running managed recovery of a standby database (v$recovery_progress).",
+		}, []string{"database", "dbinstance", "type"}),
+		dgDestLag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "dataguard",
+			Name:      "destination_lag_seconds",
+			Help:      "Age of the newest log archived to (transport) and applied by (apply) a standby destination of a primary database (v$archive_dest_status, v$archived_log).",
+		}, []string{"database", "dbinstance", "dest_name", "db_unique_name", "type"}),
+		dgStatus: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "dataguard",
+			Name:      "status",
+			Help:      "Always 1, the role, protection mode and level and the switchover status of the database in labels (v$database).",
+		}, []string{"database", "dbinstance", "role", "protection_mode", "protection_level", "switchover_status"}),
+		dgMember: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "dataguard",
+			Name:      "broker_member_status",
+			Help:      "Status of a member of the Data Guard broker configuration, 0 if SUCCESS else the ORA- error number (v$dg_broker_config).",
+		}, []string{"database", "dbinstance", "member", "role", "enabled"}),
+		fsfoStatus: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "dataguard",
+			Name:      "fsfo_status",
+			Help:      "Always 1, the fast-start failover status, e.g. DISABLED or SYNCHRONIZED, and its current target in labels (v$database).",
+		}, []string{"database", "dbinstance", "status", "target"}),
+		fsfoObsrv: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "dataguard",
+			Name:      "fsfo_observer_present",
+			Help:      "1 if the fast-start failover observer is connected to the database, else 0 (v$database).",
+		}, []string{"database", "dbinstance", "observer_host"}),
+		archDest: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "archive_dest",
+			Name:      "status",
+			Help:      "1 if the status of an active archive destination is VALID, else 0, the status in a label (v$archive_dest).",
+		}, []string{"database", "dbinstance", "dest_name", "destination", "status"}),
+		archErr: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "archive_dest",
+			Name:      "error_code",
+			Help:      "ORA- error number of the last error of an active archive destination, 0 without error (v$archive_dest).",
+		}, []string{"database", "dbinstance", "dest_name", "destination"}),
+		archGap: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "archive_dest",
+			Name:      "sequence_gap",
+			Help:      "Log sequences the last log archived to a destination is behind the current log of its thread (v$archive_dest_status, v$log).",
+		}, []string{"database", "dbinstance", "dest_name", "destination"}),
+		blocking: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "blocking_sessions",
+			Help:      "Gauge metric with the sessions blocking others (type blocker) and the sessions waiting for another one (type blocked) (v$session).",
+		}, []string{"database", "dbinstance", "type"}),
+		blockMax: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "blocking_max_seconds",
+			Help:      "Longest time a blocked session has been waiting for its blocker (v$session).",
+		}, []string{"database", "dbinstance"}),
+		blockers: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "blocker_blocked_sessions",
+			Help:      "Sessions blocked by a blocker with its sid and username, for the blockers of the most sessions only, with blocking.top (v$session).",
+		}, []string{"database", "dbinstance", "blocker_sid", "blocker_username"}),
+		longopPct: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "longops",
+			Name:      "progress_ratio",
+			Help:      "Work done of a running long operation, sofar/totalwork (v$session_longops).",
+		}, []string{"database", "dbinstance", "opname", "target", "sid", "username"}),
+		longopTime: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "longops",
+			Name:      "elapsed_seconds",
+			Help:      "Seconds a running long operation has been running (v$session_longops).",
+		}, []string{"database", "dbinstance", "opname", "target", "sid", "username"}),
+		longopLeft: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "longops",
+			Name:      "remaining_seconds",
+			Help:      "Seconds a running long operation is estimated to take until it is done (v$session_longops).",
+		}, []string{"database", "dbinstance", "opname", "target", "sid", "username"}),
+		undoBytes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "undo",
+			Name:      "bytes",
+			Help:      "Bytes of the undo extents per undo tablespace and status active, unexpired or expired (dba_undo_extents).",
+		}, []string{"database", "dbinstance", "tablespace", "status"}),
+		undoSnapTO: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "undo",
+			Name:      "snapshot_too_old_errors",
+			Help:      "ORA-01555 snapshot too old errors of the last 10 minute interval per undo tablespace (v$undostat).",
+		}, []string{"database", "dbinstance", "tablespace"}),
+		undoMaxQry: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "undo",
+			Name:      "max_query_seconds",
+			Help:      "Length of the longest query of the last 10 minute interval per undo tablespace, to compare with undo_retention (v$undostat).",
+		}, []string{"database", "dbinstance", "tablespace"}),
+		tempBytes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "temp",
+			Name:      "bytes",
+			Help:      "Bytes of a temporary tablespace, type total of its tempfiles, allocated extents, used by active sorts and free for them (v$temp_space_header, v$sort_segment).",
+		}, []string{"database", "dbinstance", "tablespace", "type"}),
+		tempUsers: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "temp",
+			Name:      "sort_users",
+			Help:      "Active users of the sort segment of a temporary tablespace (v$sort_segment).",
+		}, []string{"database", "dbinstance", "tablespace"}),
+		resLimit: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "resource_limit",
+			Help:      "Gauge metric with the current and max utilization and the limit of processes, sessions, transactions and parallel servers, no limit if unlimited (v$resource_limit).",
+		}, []string{"database", "dbinstance", "resource", "type"}),
+		asmDiskOps: newCounterVec("asm_disk", "operations_total",
+			"Reads and writes of an ASM disk since its diskgroup was mounted (v$asm_disk_stat).", "database", "dbinstance", "diskgroup", "disk", "type"),
+		asmDiskErr: newCounterVec("asm_disk", "errors_total",
+			"Failed reads and writes of an ASM disk since its diskgroup was mounted (v$asm_disk_stat).", "database", "dbinstance", "diskgroup", "disk", "type"),
+		asmDiskUp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "asm_disk",
+			Name:      "online",
+			Help:      "1 if the mode status of an ASM disk is ONLINE, else 0, the mode status and state in labels (v$asm_disk_stat).",
+		}, []string{"database", "dbinstance", "diskgroup", "disk", "mode_status", "state"}),
+		asmDgBytes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "asm_diskgroup",
+			Name:      "bytes",
+			Help:      "Redundancy headroom of an ASM diskgroup, type usable_file (free space usable for files after restoring redundancy after a disk failure, negative if it could not be restored) and required_mirror_free (v$asm_diskgroup_stat).",
+		}, []string{"database", "dbinstance", "diskgroup", "type"}),
+		asmRebPwr: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "asm_operation",
+			Name:      "power",
+			Help:      "Power of a running ASM operation like a rebalance, with its state (v$asm_operation).",
+		}, []string{"database", "dbinstance", "diskgroup", "operation", "state"}),
+		asmRebLeft: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "asm_operation",
+			Name:      "remaining_seconds",
+			Help:      "Estimated time until a running ASM operation like a rebalance is done (v$asm_operation est_minutes).",
+		}, []string{"database", "dbinstance", "diskgroup", "operation", "state"}),
+		enqReqs: newCounterVec("enqueue", "requests_total",
+			"Requests of an enqueue type since the start of the instance (v$enqueue_stat).", "database", "dbinstance", "enqueue_type"),
+		enqWaits: newCounterVec("enqueue", "waits_total",
+			"Requests of an enqueue type which had to wait since the start of the instance (v$enqueue_stat).", "database", "dbinstance", "enqueue_type"),
+		enqWaitSec: newCounterVec("enqueue", "wait_seconds_total",
+			"Time waited for an enqueue type since the start of the instance (v$enqueue_stat).", "database", "dbinstance", "enqueue_type"),
 		tablerows: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Name:      "tablerows",
@@ -230,155 +806,426 @@ func NewExporter() *Exporter {
 			Name:      "lobbytes",
 			Help:      "Gauge metric with bytes of all Lobs per Table.",
 		}, []string{"database", "dbinstance", "owner", "table_name"}),
+		objchanges: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "objectchanges",
+			Help:      "Gauge metric with number of objects changed by DDL in the lookback window per owner (dba_objects).",
+		}, []string{"database", "dbinstance", "owner"}),
+		userstat: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "user_stat",
+			Help:      "Gauge metric with v$sesstat statistics of the connected sessions summed per username, top users only.",
+		}, []string{"database", "dbinstance", "username", "stat"}),
+		debugTime: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter_debug",
+			Name:      "collector_seconds",
+			Help:      "Duration of each collector in this scrape, only with the header X-Debug-Scrape: 1.",
+		}, []string{"database", "dbinstance", "collector"}),
+		debugRows: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter_debug",
+			Name:      "custom_rows",
+			Help:      "Rows returned by each custom query in this scrape, only with the header X-Debug-Scrape: 1.",
+		}, []string{"database", "dbinstance", "query"}),
+		debugSql: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter_debug",
+			Name:      "sql_elapsed_seconds",
+			Help:      "Total elapsed time of the most expensive statements parsed by the exporter's user (v$sql), only with the header X-Debug-Scrape: 1.",
+		}, []string{"database", "dbinstance", "sql_id", "sql_text"}),
+		failLogons: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "security",
+			Name:      "failed_logons",
+			Help:      "Gauge metric with failed logons in the lookback window per username and ORA- return code, top users only (unified_audit_trail or dba_audit_trail).",
+		}, []string{"database", "dbinstance", "username", "returncode"}),
+		lockouts: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "security",
+			Name:      "account_lockouts",
+			Help:      "Gauge metric with accounts locked in the lookback window (dba_users.lock_date).",
+		}, []string{"database", "dbinstance"}),
+		watched: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "watched_sessions",
+			Help:      "Gauge metric with active sessions of the watched usernames/modules per current wait event, ON CPU if not waiting (v$session).",
+		}, []string{"database", "dbinstance", "username", "module", "wait_class", "event"}),
+		watchWait: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "watched_sessions_wait_seconds",
+			Help:      "Gauge metric with the summed time the active sessions of the watched usernames/modules are waiting in their current wait event (v$session).",
+		}, []string{"database", "dbinstance", "username", "module", "wait_class", "event"}),
+		oemUp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "oem",
+			Name:      "target_up",
+			Help:      "1 if the availability status of the target in the OEM repository is Target Up, else 0 (sysman.mgmt$availability_current).",
+		}, []string{"database", "dbinstance", "target", "target_type", "host", "status"}),
+		oemMetric: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "oem",
+			Name:      "metric",
+			Help:      "Gauge metric with the current value of a metric column collected by OEM (sysman.mgmt$metric_current).",
+		}, []string{"database", "dbinstance", "target", "target_type", "metric", "column", "key"}),
+		aqMessages: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "aq",
+			Name:      "messages",
+			Help:      "Gauge metric with the waiting, ready and expired messages of an Advanced Queuing queue summed over the instances (gv$aq).",
+		}, []string{"database", "dbinstance", "owner", "queue", "state"}),
+		aqAge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "aq",
+			Name:      "oldest_message_age_seconds",
+			Help:      "Gauge metric with the age of the oldest ready or waiting message of a queue in the queue_tables of the aq section.",
+		}, []string{"database", "dbinstance", "owner", "queue"}),
+		skippedCol: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: exporter,
+			Name:      "custom_skipped_columns",
+			Help:      "Metric and label columns of custom queries skipped because of an unsupported type (LOB, LONG, RAW, ...).",
+		}, []string{"database", "dbinstance", "query", "column", "type"}),
 		custom: make(map[string]*prometheus.GaugeVec),
-		used_times: prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "collect_used_times",
-				Help:      "this prometheus oracle exporter used time",
-			},
-			[]string{"ipport", "svname", "column"},
-		),
+		series: make(map[string]map[uint64]prometheus.Labels),
 	}
-
-	addCustomsql(&e)
-	return &e
 }
 
+// addCustomsql counts the custom queries of the (re)loaded config, their vectors are
+// created by the scrapes.
 func addCustomsql(e *Exporter) {
 	cfgLok.Lock()
 	defer cfgLok.Unlock()
-	// add custom metrics
+	e.customCount.Reset()
+	e.configGen.Inc()
 	for _, conn := range config.Cfgs {
-		for _, query := range conn.Queries {
-			labels := []string{}
-			for _, label := range query.Labels {
-				labels = append(labels, cleanName(label))
+		e.customCount.WithLabelValues(conn.Database, conn.Instance).Set(float64(len(conn.Queries)))
+	}
+}
+
+// customVec returns the vector of a custom query in m, created on first use.
+// The caller holds customLok.
+func (m *metricSet) customVec(query Query) *prometheus.GaugeVec {
+	vec, ok := m.custom[query.Name]
+	if !ok {
+		labels := []string{}
+		for _, label := range query.Labels {
+			labels = append(labels, cleanName(label))
+		}
+		if query.Rac {
+			labels = append(labels, "inst_id")
+		}
+		vec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "custom_" + cleanName(query.Name),
+			Help:      query.Help,
+		}, append(labels, "metric", "database", "dbinstance", "rownum"))
+		m.custom[query.Name] = vec
+	}
+	return vec
+}
+
+// setCustom sets one series of a custom query. A query returning more than -custom.max-series
+// distinct label sets for a target is dropped for that target from this scrape, a label with
+// unbounded values (ids, timestamps, SQL text) would otherwise grow the memory of the exporter
+// and of Prometheus. The vectors are created anew by every scrape, so the label sets are
+// counted from the start of the scrape.
+func (e *Exporter) setCustom(query Query, labels prometheus.Labels, value float64) {
+	e.customLok.Lock()
+	defer e.customLok.Unlock()
+	vec := e.customVec(query)
+	if *maxSeries <= 0 {
+		vec.With(labels).Set(value)
+		return
+	}
+	key := query.Name + "/" + labels["database"] + "/" + labels["dbinstance"]
+	set, ok := e.series[key]
+	if ok && set == nil {
+		// capped
+		return
+	}
+	if set == nil {
+		set = make(map[uint64]prometheus.Labels)
+		e.series[key] = set
+	}
+	sig := model.LabelsToSignature(labels)
+	if _, seen := set[sig]; !seen {
+		if len(set) >= *maxSeries {
+			// only the series of this target, the other targets share the vector
+			for _, l := range set {
+				vec.Delete(l)
 			}
-			e.custom[query.Name] = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "custom_" + cleanName(query.Name),
-				Help:      query.Help,
-			}, append(labels, "metric", "database", "dbinstance", "rownum"))
+			e.series[key] = nil
+			e.seriesCapped.WithLabelValues(labels["database"], labels["dbinstance"], query.Name).Inc()
+			log.Warnf(" %s returned more than %d label sets for %s, dropped from this scrape (-custom.max-series)", query.Name, *maxSeries, labels["database"])
+			return
+		}
+		// the caller reuses labels for the next metric of the row
+		own := make(prometheus.Labels, len(labels))
+		for k, v := range labels {
+			own[k] = v
+		}
+		set[sig] = own
+	}
+	vec.With(labels).Set(value)
+}
+
+// customColumnTypes are the driver column types usable as metric or label in custom queries.
+// Everything else (CLOB, BLOB, BFILE, LONG, RAW, XMLType, cursors) is skipped with a warning.
+var customColumnTypes = map[string]bool{
+	"NUMBER": true, "FLOAT": true, "VarNum": true, "UINT": true,
+	"BFloat": true, "BDouble": true, "IBFloat": true, "IBDouble": true,
+	"CHAR": true, "CHARZ": true, "NCHAR": true, "VARCHAR": true,
+	"ROWID": true, "UROWID": true,
+	"DATE": true, "OCIDate": true,
+	"TimeStamp": true, "TimeStampTZ": true, "TimeStampeLTZ": true,
+	"TimeStampDTY": true, "TimeStampTZ_DTY": true, "TimeStampLTZ_DTY": true,
+}
+
+// unsupportedColumns validates the column types of a custom query result and returns
+// the indexes of the metric and label columns that can not be exported.
+func (e *Exporter) unsupportedColumns(conn *Config, query Query, rows *sql.Rows) map[int]bool {
+	skip := make(map[int]bool)
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return skip
+	}
+	used := append(append([]string{}, query.Metrics...), query.Labels...)
+	for i, ct := range types {
+		typ := ct.DatabaseTypeName()
+		if customColumnTypes[typ] {
+			continue
+		}
+		for _, name := range used {
+			if cleanName(name) != cleanName(ct.Name()) {
+				continue
+			}
+			skip[i] = true
+			e.skippedCol.WithLabelValues(conn.Database, conn.Instance, query.Name, name, typ).Set(1)
+			if _, warned := e.skipWarned.LoadOrStore(query.Name+"/"+name, true); !warned {
+				log.Warnf(" %s column %s has unsupported type %s, skipped", query.Name, name, typ)
+			}
+			break
+		}
+	}
+	return skip
+}
+
+// columnIndex returns the index of the column name in cols, or -1.
+func columnIndex(cols []string, name string) int {
+	for i, col := range cols {
+		if cleanName(name) == cleanName(col) {
+			return i
+		}
+	}
+	return -1
+}
+
+// customValue converts a scanned column to a metric value. DATE and TIMESTAMP columns
+// are converted to Unix seconds if the query has value_type timestamp.
+func customValue(query Query, v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int64:
+		// the driver returns integral NUMBERs as int64
+		return float64(v), true
+	case time.Time:
+		if query.ValueType == "timestamp" {
+			return float64(v.UnixNano()) / 1e9, true
 		}
 	}
+	return 0, false
 }
 
 // ScrapeCustomQueries collects metrics from self defined queries from configuration file.
-func (e *Exporter) ScrapeCustomQueries(conn *Config) {
+// The queries run independently, a failing query does not stop the others and is disabled
+// on its own after repeated ORA-00942 as custom/<name>.
+func (e *Exporter) ScrapeCustomQueries(ctx context.Context, conn *Config) error {
 	defer func() {
 		if e := recover(); e != nil {
 			log.Errorln(" ?", e)
 		}
 	}()
-	var (
-		rows *sql.Rows
-		err  error
-	)
-	{
-		if conn.db != nil {
-			for _, query := range conn.Queries {
-				rows, err = conn.db.QueryContext(e.gctx, query.Sql)
-				if err != nil {
-					return
-				}
+	if conn.db == nil {
+		return nil
+	}
+	var instIDs []string
+	for _, query := range conn.Queries {
+		name := "custom/" + query.Name
+		if e.isDisabled(conn, name) {
+			continue
+		}
+		err := e.scrapeCustom(ctx, conn, query, &instIDs)
+		if err != nil {
+			e.scrapeErrors.WithLabelValues("custom").Inc()
+			conn.scrapeFailed(name, err)
+		}
+		e.countMissing(conn, name, err)
+	}
+	return nil
+}
+
+// scrapeCustom runs one custom query, a rac query once per instance of instIDs, which are
+// read on first use.
+func (e *Exporter) scrapeCustom(ctx context.Context, conn *Config, query Query, instIDs *[]string) error {
+	if !query.Rac {
+		return e.scrapeCustomQuery(ctx, conn, query, query.Sql, "")
+	}
+	if *instIDs == nil {
+		ids, err := instanceIDs(ctx, conn)
+		if err != nil {
+			return err
+		}
+		*instIDs = ids
+	}
+	for _, id := range *instIDs {
+		if err := e.scrapeCustomQuery(ctx, conn, query, racSQL(query.Sql, id), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-				cols, _ := rows.Columns()
-				vals := make([]interface{}, len(cols))
+// scrapeCustomQuery runs sqlText of a custom query, instID is the inst_id label of a rac query.
+func (e *Exporter) scrapeCustomQuery(ctx context.Context, conn *Config, query Query, sqlText, instID string) error {
+	rows, err := conn.db.QueryContext(ctx, sqlText)
+	if err != nil {
+		return err
+	}
 
-				defer rows.Close()
-				var rownum int = 1
+	cols, _ := rows.Columns()
+	vals := make([]interface{}, len(cols))
+	skip := e.unsupportedColumns(conn, query, rows)
 
-			QueryLoop:
-				for rows.Next() {
-					for i := range cols {
-						vals[i] = &vals[i]
-					}
+	var rownum int = 1
+	// an error of each derived metric, a division by zero only if it has no other, and
+	// the rows failing, logged once per query
+	derivedErrs := make(map[string]error)
+	derivedFails := make(map[string]int)
 
-					err = rows.Scan(vals...)
-					if err != nil {
-						break
-					}
+QueryLoop:
+	for rows.Next() {
+		for i := range cols {
+			vals[i] = &vals[i]
+		}
 
-				MetricLoop:
-					for _, metric := range query.Metrics {
-						metricColumnIndex := -1
-						for i, col := range cols {
-							if cleanName(metric) == cleanName(col) {
-								metricColumnIndex = i
-								break
-							}
-						}
-
-						if metricColumnIndex == -1 {
-							//log.Infoln("Metric column '" + metric + "' not found")
-							// missing Metric can skip this metric
-							continue MetricLoop
-						}
-
-						if metricValue, ok := vals[metricColumnIndex].(float64); ok {
-							promLabels := prometheus.Labels{}
-							promLabels["database"] = conn.Database
-							promLabels["dbinstance"] = conn.Instance
-							promLabels["metric"] = metric
-							promLabels["rownum"] = strconv.Itoa(rownum)
-
-							for _, label := range query.Labels {
-								labelColumnIndex := -1
-								for i, col := range cols {
-									if cleanName(label) == cleanName(col) {
-										labelColumnIndex = i
-										break
-									}
-								}
-
-								if labelColumnIndex == -1 {
-									// missing Label skip this query
-									log.Warnf(" %s Label %s not found", query.Name, label)
-									break QueryLoop
-								}
-
-								if a, ok := vals[labelColumnIndex].(string); ok {
-									promLabels[cleanName(label)] = a
-								} else if b, ok := vals[labelColumnIndex].(float64); ok {
-									// if value is integer
-									if b == float64(int64(b)) {
-										promLabels[cleanName(label)] = strconv.Itoa(int(b))
-									} else {
-										promLabels[cleanName(label)] = strconv.FormatFloat(b, 'e', -1, 64)
-									}
-								} else {
-									// catch other type
-									promLabels[cleanName(label)] = fmt.Sprintf("%v", b)
-								}
-							}
-							e.custom[query.Name].With(promLabels).Set(metricValue)
-						}
-					}
+		err = rows.Scan(vals...)
+		if err != nil {
+			break
+		}
+
+		promLabels := prometheus.Labels{}
+		promLabels["database"] = conn.Database
+		promLabels["dbinstance"] = conn.Instance
+		promLabels["rownum"] = strconv.Itoa(rownum)
+		if query.Rac {
+			promLabels["inst_id"] = instID
+		}
+
+		for _, label := range query.Labels {
+			labelColumnIndex := columnIndex(cols, label)
+			if labelColumnIndex == -1 {
+				// missing Label skip this query
+				log.Warnf(" %s Label %s not found", query.Name, label)
+				break QueryLoop
+			}
 
-					rownum++
+			if skip[labelColumnIndex] {
+				// unsupported type, keep the label but leave it empty
+				promLabels[cleanName(label)] = ""
+			} else if a, ok := vals[labelColumnIndex].(string); ok {
+				promLabels[cleanName(label)] = a
+			} else if b, ok := vals[labelColumnIndex].(float64); ok {
+				// if value is integer
+				if b == float64(int64(b)) {
+					promLabels[cleanName(label)] = strconv.Itoa(int(b))
+				} else {
+					promLabels[cleanName(label)] = strconv.FormatFloat(b, 'e', -1, 64)
 				}
+			} else {
+				// catch other type
+				promLabels[cleanName(label)] = fmt.Sprintf("%v", b)
 			}
 		}
-	}
-}
 
-// ScrapeQuery collects metrics from self defined queries from configuration file.
-// func (e *Exporter) ScrapeQuery() {
-// 	var (
-// 		rows *sql.Rows
-// 		err  error
-// 	)
-// 	for _, conn := range config.Cfgs {
-// 		if conn.db != nil {
-// 			for _, query := range conn.Queries {
-// 				rows, err = conn.db.QueryContext(e.gctx, query.Sql)
-// 				if err != nil {
-// 					continue
-// 				}
+		// numeric columns by name, the variables of derived metrics
+		columns := make(map[string]float64)
 
-// 				cols, _ := rows.Columns()
-// 				vals := make([]interface{}, len(cols))
+	MetricLoop:
+		for _, metric := range query.Metrics {
+			metricColumnIndex := columnIndex(cols, metric)
+			if metricColumnIndex == -1 || skip[metricColumnIndex] {
+				//log.Infoln("Metric column '" + metric + "' not found")
+				// missing or unsupported Metric can skip this metric
+				continue MetricLoop
+			}
+
+			if metricValue, ok := customValue(query, vals[metricColumnIndex]); ok {
+				promLabels["metric"] = metric
+				e.setCustom(query, promLabels, metricValue)
+			}
+		}
+
+		if len(query.Derived) > 0 {
+			for i, col := range cols {
+				if value, ok := customValue(query, vals[i]); ok && !skip[i] {
+					columns[cleanName(col)] = value
+				}
+			}
+		}
+		for _, derived := range query.Derived {
+			value, err := evalExpr(derived.Expr, columns)
+			if err != nil {
+				if derivedErrs[derived.Name] == nil || derivedErrs[derived.Name] == errDivZero {
+					derivedErrs[derived.Name] = err
+				}
+				derivedFails[derived.Name]++
+				continue
+			}
+			promLabels["metric"] = derived.Name
+			e.setCustom(query, promLabels, value)
+		}
+
+		rownum++
+	}
+	for name, err := range derivedErrs {
+		if err == errDivZero {
+			// e.g. a ratio of an idle instance
+			log.Debugf(" %s derived metric %s: %v in %d of %d rows", query.Name, name, err, derivedFails[name], rownum-1)
+		} else {
+			log.Warnf(" %s derived metric %s: %v in %d of %d rows", query.Name, name, err, derivedFails[name], rownum-1)
+		}
+	}
+	if e.opts.debug {
+		e.debugRows.WithLabelValues(conn.Database, conn.Instance, query.Name).Add(float64(rownum - 1))
+	}
+	if err == nil {
+		// a fetch cut off by the deadline or an ORA- error, the rows so far are partial
+		err = rows.Err()
+	}
+	// close before the next query, a deferred close would keep the cursors
+	// of all queries open until the end (ORA-01000 with many queries)
+	rows.Close()
+	return err
+}
+
+// ScrapeQuery collects metrics from self defined queries from configuration file.
+// func (e *Exporter) ScrapeQuery() {
+// 	var (
+// 		rows *sql.Rows
+// 		err  error
+// 	)
+// 	for _, conn := range config.Cfgs {
+// 		if conn.db != nil {
+// 			for _, query := range conn.Queries {
+// 				rows, err = conn.db.QueryContext(e.gctx, query.Sql)
+// 				if err != nil {
+// 					continue
+// 				}
+
+// 				cols, _ := rows.Columns()
+// 				vals := make([]interface{}, len(cols))
 // 				var rownum int = 1
 
 // 				defer rows.Close()
@@ -405,7 +1252,7 @@ func (e *Exporter) ScrapeCustomQueries(conn *Config) {
 // }
 
 // ScrapeParameters collects metrics from the v$parameters view.
-func (e *Exporter) ScrapeParameter(conn *Config) {
+func (e *Exporter) ScrapeParameter(ctx context.Context, conn *Config) error {
 	var (
 		rows *sql.Rows
 		err  error
@@ -414,9 +1261,9 @@ func (e *Exporter) ScrapeParameter(conn *Config) {
 		//num  metric_name
 		//43  sessions
 		if conn.db != nil {
-			rows, err = conn.db.QueryContext(e.gctx, `select name,value from v$parameter WHERE num=43`)
+			rows, err = conn.db.QueryContext(ctx, `select name,value from v$parameter WHERE num=43`)
 			if err != nil {
-				return
+				return err
 			}
 			defer rows.Close()
 			for rows.Next() {
@@ -430,19 +1277,172 @@ func (e *Exporter) ScrapeParameter(conn *Config) {
 			}
 		}
 	}
+	return nil
+}
+
+// ScrapeParameterChanges collects the isdefault/ismodified flags from the v$parameter view
+// and counts parameters whose value changed since the last scrape.
+func (e *Exporter) ScrapeParameterChanges(ctx context.Context, conn *Config) error {
+	var (
+		rows *sql.Rows
+		err  error
+	)
+	{
+		if conn.db != nil {
+			rows, err = conn.db.QueryContext(ctx, `select name, value, isdefault, ismodified from v$parameter`)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+			values := make(map[string]string)
+			for rows.Next() {
+				var name, isdefault, ismodified string
+				var value sql.NullString
+				if err := rows.Scan(&name, &value, &isdefault, &ismodified); err != nil {
+					return err
+				}
+				name = cleanName(name)
+				values[name] = value.String
+				if isdefault != "TRUE" || ismodified != "FALSE" {
+					e.paramstate.WithLabelValues(conn.Database, conn.Instance, name, isdefault, ismodified).Set(1)
+				}
+			}
+			if err := rows.Err(); err != nil {
+				// the values so far are no baseline for the next scrape
+				return err
+			}
+
+			key := conn.Database + "/" + conn.Instance
+			e.paramLok.Lock()
+			defer e.paramLok.Unlock()
+			for name := range values {
+				// every parameter has its series from the first scrape on, so increase()
+				// sees the first change
+				e.paramchanges.WithLabelValues(conn.Database, conn.Instance, name).Add(0)
+			}
+			if old, ok := e.paramValues[key]; ok {
+				for name, value := range values {
+					if oldValue, ok := old[name]; ok && oldValue != value {
+						log.Infof("%s parameter %s changed from %q to %q", key, name, oldValue, value)
+						e.paramchanges.WithLabelValues(conn.Database, conn.Instance, name).Inc()
+					}
+				}
+			}
+			e.paramValues[key] = values
+		}
+	}
+	return nil
+}
+
+// ScrapeComponents collects the status of installed components from the dba_registry view.
+func (e *Exporter) ScrapeComponents(ctx context.Context, conn *Config) error {
+	var (
+		rows *sql.Rows
+		err  error
+	)
+	{
+		if conn.db != nil {
+			rows, err = conn.db.QueryContext(ctx, `select comp_id, comp_name, version, status from dba_registry`)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+			for rows.Next() {
+				var id, name, version, status string
+				if err := rows.Scan(&id, &name, &version, &status); err != nil {
+					break
+				}
+				var value float64
+				if status == "VALID" {
+					value = 1
+				}
+				e.component.WithLabelValues(conn.Database, conn.Instance, id, name, version, status).Set(value)
+			}
+		}
+	}
+	return nil
+}
+
+// ScrapeDirectories collects the directory objects and the external tables using them
+// from the dba_directories and dba_external_tables views, the filesystem exposed by the DB.
+func (e *Exporter) ScrapeDirectories(ctx context.Context, conn *Config) error {
+	var (
+		rows *sql.Rows
+		err  error
+	)
+	{
+		if conn.db != nil {
+			rows, err = conn.db.QueryContext(ctx, `select owner, directory_name, directory_path from dba_directories`)
+			if err != nil {
+				return err
+			}
+			for rows.Next() {
+				var owner, name string
+				var path sql.NullString
+				if err := rows.Scan(&owner, &name, &path); err != nil {
+					break
+				}
+				e.directory.WithLabelValues(conn.Database, conn.Instance, owner, name, path.String).Set(1)
+			}
+			rows.Close()
+
+			rows, err = conn.db.QueryContext(ctx, `select owner, nvl(default_directory_name, ' '), count(*)
+                                 from dba_external_tables
+                                 group by owner, default_directory_name`)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+			for rows.Next() {
+				var owner, name string
+				var value float64
+				if err := rows.Scan(&owner, &name, &value); err != nil {
+					break
+				}
+				e.exttables.WithLabelValues(conn.Database, conn.Instance, owner, strings.TrimSpace(name)).Set(value)
+			}
+		}
+	}
+	return nil
+}
+
+// ScrapePatch collects the latest applied patch from the dba_registry_sqlpatch view (12c+).
+func (e *Exporter) ScrapePatch(ctx context.Context, conn *Config) error {
+	var (
+		id, description, action, status, applied string
+		unix                                     float64
+	)
+	{
+		if conn.db != nil {
+			err := conn.db.QueryRowContext(ctx, `select to_char(patch_id), nvl(description, ' '), action, status,
+                                 to_char(action_time, 'YYYY-MM-DD HH24:MI:SS'),
+                                 (cast(sys_extract_utc(action_time) as date) - date '1970-01-01')*86400
+                                 from (select * from dba_registry_sqlpatch order by action_time desc)
+                                 where rownum = 1`).Scan(&id, &description, &action, &status, &applied, &unix)
+			if err == sql.ErrNoRows {
+				return nil // no patch applied
+			}
+			if err != nil {
+				return err // before 12c
+			}
+			e.patch.WithLabelValues(conn.Database, conn.Instance, id, description, action, status, applied).Set(1)
+			e.patchdate.WithLabelValues(conn.Database, conn.Instance).Set(unix)
+		}
+	}
+	return nil
 }
 
 // ScrapeServices collects metrics from the v$active_services view.
-func (e *Exporter) ScrapeServices(conn *Config) {
+func (e *Exporter) ScrapeServices(ctx context.Context, conn *Config) error {
 	var (
 		rows *sql.Rows
 		err  error
 	)
 	{
 		if conn.db != nil {
-			rows, err = conn.db.QueryContext(e.gctx, `select name from v$active_services`)
+			rows, err = conn.db.QueryContext(ctx, `select name from v$active_services`)
 			if err != nil {
-				return
+				return err
 			}
 			defer rows.Close()
 			for rows.Next() {
@@ -455,10 +1455,11 @@ func (e *Exporter) ScrapeServices(conn *Config) {
 			}
 		}
 	}
+	return nil
 }
 
 // ScrapeCache collects session metrics from the v$sysmetrics view.
-func (e *Exporter) ScrapeCache(conn *Config) {
+func (e *Exporter) ScrapeCache(ctx context.Context, conn *Config) error {
 	var (
 		rows *sql.Rows
 		err  error
@@ -470,11 +1471,11 @@ func (e *Exporter) ScrapeCache(conn *Config) {
 		//2112    Library Cache Hit Ratio
 		//2110    Row Cache Hit Ratio
 		if conn.db != nil {
-			rows, err = conn.db.QueryContext(e.gctx, `select metric_name,value
+			rows, err = conn.db.QueryContext(ctx, `select metric_name,value
                                  from v$sysmetric
                                  where group_id=2 and metric_id in (2000,2050,2112,2110)`)
 			if err != nil {
-				return
+				return err
 			}
 			defer rows.Close()
 			for rows.Next() {
@@ -488,19 +1489,20 @@ func (e *Exporter) ScrapeCache(conn *Config) {
 			}
 		}
 	}
+	return nil
 }
 
 // ScrapeRecovery collects tablespace metrics
-func (e *Exporter) ScrapeRedo(conn *Config) {
+func (e *Exporter) ScrapeRedo(ctx context.Context, conn *Config) error {
 	var (
 		rows *sql.Rows
 		err  error
 	)
 	{
 		if conn.db != nil {
-			rows, err = conn.db.QueryContext(e.gctx, `select count(*) from v$log_history where first_time > sysdate - 1/24/12`)
+			rows, err = conn.db.QueryContext(ctx, `select count(*) from v$log_history where first_time > sysdate - 1/24/12`)
 			if err != nil {
-				return
+				return err
 			}
 			defer rows.Close()
 			for rows.Next() {
@@ -512,20 +1514,21 @@ func (e *Exporter) ScrapeRedo(conn *Config) {
 			}
 		}
 	}
+	return nil
 }
 
 // ScrapeRecovery collects tablespace metrics
-func (e *Exporter) ScrapeRecovery(conn *Config) {
+func (e *Exporter) ScrapeRecovery(ctx context.Context, conn *Config) error {
 	var (
 		rows *sql.Rows
 		err  error
 	)
 	{
 		if conn.db != nil {
-			rows, err = conn.db.QueryContext(e.gctx, `SELECT sum(percent_space_used) , sum(percent_space_reclaimable)
+			rows, err = conn.db.QueryContext(ctx, `SELECT sum(percent_space_used) , sum(percent_space_reclaimable)
                                  from V$FLASH_RECOVERY_AREA_USAGE`)
 			if err != nil {
-				return
+				return err
 			}
 			defer rows.Close()
 			for rows.Next() {
@@ -539,23 +1542,25 @@ func (e *Exporter) ScrapeRecovery(conn *Config) {
 			}
 		}
 	}
+	return nil
 }
 
 // ScrapeTablespaces collects tablespace metrics
-func (e *Exporter) ScrapeInterconnect(conn *Config) {
+func (e *Exporter) ScrapeInterconnect(ctx context.Context, conn *Config) error {
 	var (
 		rows *sql.Rows
 		err  error
 	)
 	{
 		if conn.db != nil {
-			rows, err = conn.db.QueryContext(e.gctx, `SELECT name, value
+			rows, err = conn.db.QueryContext(ctx, `SELECT name, value
                                  FROM V$SYSSTAT
-                                 WHERE name in ('gc cr blocks served','gc cr blocks flushed','gc cr blocks received')`)
+                                 WHERE name in ('gc cr blocks served','gc cr blocks flushed','gc cr blocks received',
+                                                'gc current blocks served','gc current blocks received',
+                                                'gc cr block receive time','gc current block receive time')`)
 			if err != nil {
-				return
+				return err
 			}
-			defer rows.Close()
 			for rows.Next() {
 				var name string
 				var value float64
@@ -565,25 +1570,66 @@ func (e *Exporter) ScrapeInterconnect(conn *Config) {
 				name = cleanName(name)
 				e.interconnect.WithLabelValues(conn.Database, conn.Instance, name).Set(value)
 			}
+			rows.Close()
+
+			// busy and congested global cache blocks, their failure is shown in /targets but
+			// keeps the statistics above and does not count towards disabling the collector
+			if err := e.scrapeGcEvents(ctx, conn); err != nil {
+				conn.scrapeFailed("interconnect", err)
+			}
+
+			// dynamic remastering
+			var ops, objects float64
+			err = conn.db.QueryRowContext(ctx, `SELECT remaster_ops, remastered_objects
+                                 FROM V$DYNAMIC_REMASTER_STATS`).Scan(&ops, &objects)
+			if err == nil {
+				e.interconnect.WithLabelValues(conn.Database, conn.Instance, "remaster_ops").Set(ops)
+				e.interconnect.WithLabelValues(conn.Database, conn.Instance, "remastered_objects").Set(objects)
+			}
+		}
+	}
+	return nil
+}
+
+// scrapeGcEvents collects the waits of busy and congested global cache blocks.
+func (e *Exporter) scrapeGcEvents(ctx context.Context, conn *Config) error {
+	rows, err := conn.db.QueryContext(ctx, `SELECT event, total_waits, time_waited_micro
+                                 FROM V$SYSTEM_EVENT
+                                 WHERE event in ('gc cr block busy','gc current block busy',
+                                                 'gc cr block congested','gc current block congested',
+                                                 'gc buffer busy acquire','gc buffer busy release')`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		var waits, micro float64
+		if err := rows.Scan(&name, &waits, &micro); err != nil {
+			return err
 		}
+		name = cleanName(name)
+		e.interconnect.WithLabelValues(conn.Database, conn.Instance, name+"_waits").Set(waits)
+		e.interconnect.WithLabelValues(conn.Database, conn.Instance, name+"_time_waited_micro").Set(micro)
 	}
+	return rows.Err()
 }
 
 // ScrapeAsmspace collects ASM metrics
-func (e *Exporter) ScrapeAsmspace(conn *Config) {
+func (e *Exporter) ScrapeAsmspace(ctx context.Context, conn *Config) error {
 	var (
 		rows *sql.Rows
 		err  error
 	)
 	{
 		if conn.db != nil {
-			rows, err = conn.db.QueryContext(e.gctx, `SELECT g.name, sum(d.total_mb), sum(d.free_mb)
+			rows, err = conn.db.QueryContext(ctx, `SELECT g.name, sum(d.total_mb), sum(d.free_mb)
                                   FROM v$asm_disk_stat d, v$asm_diskgroup_stat g
                                  WHERE  d.group_number = g.group_number
                                   AND  d.header_status = 'MEMBER'
                                  GROUP by  g.name,  g.group_number`)
 			if err != nil {
-				return
+				return err
 			}
 			defer rows.Close()
 			for rows.Next() {
@@ -597,19 +1643,444 @@ func (e *Exporter) ScrapeAsmspace(conn *Config) {
 				e.asmspace.WithLabelValues(conn.Database, conn.Instance, "free", name).Set(tfree)
 				e.asmspace.WithLabelValues(conn.Database, conn.Instance, "used", name).Set(tsize - tfree)
 			}
+			if err := e.scrapeAsmDisks(ctx, conn); err != nil {
+				return err
+			}
+			return e.scrapeAsmOperations(ctx, conn)
+		}
+	}
+	return nil
+}
+
+// scrapeAsmOperations collects the redundancy headroom of the diskgroups and the power and the
+// estimated remaining time of running operations like rebalances.
+func (e *Exporter) scrapeAsmOperations(ctx context.Context, conn *Config) error {
+	rows, err := conn.db.QueryContext(ctx, `SELECT name, usable_file_mb, required_mirror_free_mb FROM v$asm_diskgroup_stat`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var group string
+		var usable, mirror float64
+		if err = rows.Scan(&group, &usable, &mirror); err != nil {
+			break
+		}
+		e.asmDgBytes.WithLabelValues(conn.Database, conn.Instance, group, "usable_file").Set(usable * 1024 * 1024)
+		e.asmDgBytes.WithLabelValues(conn.Database, conn.Instance, group, "required_mirror_free").Set(mirror * 1024 * 1024)
+	}
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	rows, err = conn.db.QueryContext(ctx, `SELECT g.name, o.operation, o.state, nvl(o.power, 0), nvl(o.est_minutes, 0)
+                                 FROM v$asm_operation o, v$asm_diskgroup_stat g
+                                 WHERE o.group_number = g.group_number`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var group, operation, state string
+		var power, minutes float64
+		if err := rows.Scan(&group, &operation, &state, &power, &minutes); err != nil {
+			return err
+		}
+		e.asmRebPwr.WithLabelValues(conn.Database, conn.Instance, group, operation, state).Set(power)
+		e.asmRebLeft.WithLabelValues(conn.Database, conn.Instance, group, operation, state).Set(minutes * 60)
+	}
+	return rows.Err()
+}
+
+// scrapeAsmDisks collects the reads, writes and errors and the online state of every ASM
+// disk of a diskgroup, to find the failing disk behind a degraded diskgroup.
+func (e *Exporter) scrapeAsmDisks(ctx context.Context, conn *Config) error {
+	rows, err := conn.db.QueryContext(ctx, `SELECT g.name, d.name, d.reads, d.writes, d.read_errs, d.write_errs, d.mode_status, d.state
+                                 FROM v$asm_disk_stat d, v$asm_diskgroup_stat g
+                                 WHERE d.group_number = g.group_number`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var group, disk, mode, state string
+		var reads, writes, readErrs, writeErrs float64
+		if err := rows.Scan(&group, &disk, &reads, &writes, &readErrs, &writeErrs, &mode, &state); err != nil {
+			return err
+		}
+		e.asmDiskOps.Set(reads, conn.Database, conn.Instance, group, disk, "read")
+		e.asmDiskOps.Set(writes, conn.Database, conn.Instance, group, disk, "write")
+		e.asmDiskErr.Set(readErrs, conn.Database, conn.Instance, group, disk, "read")
+		e.asmDiskErr.Set(writeErrs, conn.Database, conn.Instance, group, disk, "write")
+		online := 0.0
+		if mode == "ONLINE" {
+			online = 1
+		}
+		e.asmDiskUp.WithLabelValues(conn.Database, conn.Instance, group, disk, mode, state).Set(online)
+	}
+	return rows.Err()
+}
+
+// ScrapeLocations sums the data and temp files per directory or ASM diskgroup, for
+// "the disk holding the datafiles is filling" alerts where node_exporter can not run.
+// The free space of a filesystem can not be read by SQL, only that of ASM diskgroups.
+func (e *Exporter) ScrapeLocations(ctx context.Context, conn *Config) error {
+	if conn.db == nil {
+		return nil
+	}
+	rows, err := conn.db.QueryContext(ctx, `select location, sum(bytes),
+                                 sum(case autoextensible when 'YES' then greatest(maxbytes, bytes) else bytes end)
+                                 from (select case when file_name like '+%' then regexp_substr(file_name, '^\+[^/]+')
+                                              else regexp_replace(file_name, '[/\][^/\]*$') end location,
+                                              bytes, maxbytes, autoextensible
+                                       from dba_data_files
+                                       union all
+                                       select case when file_name like '+%' then regexp_substr(file_name, '^\+[^/]+')
+                                              else regexp_replace(file_name, '[/\][^/\]*$') end,
+                                              bytes, maxbytes, autoextensible
+                                       from dba_temp_files)
+                                 group by location`)
+	if err != nil {
+		return err
+	}
+	asm := false
+	for rows.Next() {
+		var location string
+		var allocated, max float64
+		if err = rows.Scan(&location, &allocated, &max); err != nil {
+			break
+		}
+		e.location.WithLabelValues(conn.Database, conn.Instance, location, "allocated").Set(allocated)
+		e.location.WithLabelValues(conn.Database, conn.Instance, location, "max").Set(max)
+		asm = asm || strings.HasPrefix(location, "+")
+	}
+	rows.Close()
+	if err != nil || !asm {
+		return err
+	}
+
+	rows, err = conn.db.QueryContext(ctx, `select '+' || name,
+                                 case when usable_file_mb > 0 then usable_file_mb else free_mb end * 1048576
+                                 from v$asm_diskgroup_stat`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var location string
+		var free float64
+		if err = rows.Scan(&location, &free); err != nil {
+			return err
+		}
+		e.locFree.WithLabelValues(conn.Database, conn.Instance, location).Set(free)
+	}
+	return rows.Err()
+}
+
+// ScrapeTempUndo collects the temporary undo (12c+) from the v$tempundostat view and the
+// temporary segments of global temporary tables from v$tempseg_usage. With temp_undo_enabled
+// the undo of GTTs is not in the undo tablespace statistics.
+func (e *Exporter) ScrapeTempUndo(ctx context.Context, conn *Config) error {
+	if conn.db == nil || conn.major() < 12 {
+		return nil
+	}
+	var txns, blocks, concurrency, nospace, snapshot float64
+	err := conn.db.QueryRowContext(ctx, `SELECT nvl(sum(txncount), 0), nvl(sum(undoblkcnt), 0), nvl(max(maxconcurrency), 0),
+                                 nvl(sum(nospaceerrcnt), 0), nvl(sum(ssolderrcnt), 0)
+                                 FROM v$tempundostat
+                                 WHERE end_time = (SELECT max(end_time) FROM v$tempundostat)`).Scan(&txns, &blocks, &concurrency, &nospace, &snapshot)
+	if err != nil {
+		return err
+	}
+	e.tempundo.WithLabelValues(conn.Database, conn.Instance, "txncount").Set(txns)
+	e.tempundo.WithLabelValues(conn.Database, conn.Instance, "undoblkcnt").Set(blocks)
+	e.tempundo.WithLabelValues(conn.Database, conn.Instance, "maxconcurrency").Set(concurrency)
+	e.tempundo.WithLabelValues(conn.Database, conn.Instance, "nospaceerrcnt").Set(nospace)
+	e.tempundo.WithLabelValues(conn.Database, conn.Instance, "ssolderrcnt").Set(snapshot)
+
+	rows, err := conn.db.QueryContext(ctx, `SELECT u.tablespace, u.segtype, sum(u.blocks * t.block_size)
+                                 FROM v$tempseg_usage u, dba_tablespaces t
+                                 WHERE u.tablespace = t.tablespace_name
+                                 GROUP BY u.tablespace, u.segtype`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tablespace, segtype string
+		var value float64
+		if err := rows.Scan(&tablespace, &segtype, &value); err != nil {
+			break
+		}
+		e.tempseg.WithLabelValues(conn.Database, conn.Instance, tablespace, segtype).Set(value)
+	}
+	return nil
+}
+
+// ScrapeUndo collects the active, unexpired and expired undo per undo tablespace, and the
+// ORA-01555 errors and the longest query of the last interval of v$undostat, to size the
+// undo tablespaces and undo_retention.
+func (e *Exporter) ScrapeUndo(ctx context.Context, conn *Config) error {
+	if conn.db == nil {
+		return nil
+	}
+	rows, err := conn.db.QueryContext(ctx, `SELECT tablespace_name, status, sum(bytes)
+                                 FROM dba_undo_extents
+                                 GROUP BY tablespace_name, status`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var tablespace, status string
+		var value float64
+		if err = rows.Scan(&tablespace, &status, &value); err != nil {
+			break
+		}
+		e.undoBytes.WithLabelValues(conn.Database, conn.Instance, tablespace, strings.ToLower(status)).Set(value)
+	}
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	rows, err = conn.db.QueryContext(ctx, `SELECT t.name, sum(u.ssolderrcnt), max(u.maxquerylen)
+                                 FROM v$undostat u, v$tablespace t
+                                 WHERE u.undotsn = t.ts#
+                                 AND u.end_time = (SELECT max(end_time) FROM v$undostat)
+                                 GROUP BY t.name`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tablespace string
+		var snapshot, maxQuery float64
+		if err := rows.Scan(&tablespace, &snapshot, &maxQuery); err != nil {
+			return err
+		}
+		e.undoSnapTO.WithLabelValues(conn.Database, conn.Instance, tablespace).Set(snapshot)
+		e.undoMaxQry.WithLabelValues(conn.Database, conn.Instance, tablespace).Set(maxQuery)
+	}
+	return rows.Err()
+}
+
+// ScrapeTemp collects the size and the allocated extents of the temporary tablespaces and the
+// space actually used by active sorts, as the tablespace collector reports the allocated
+// extents, which a temporary tablespace keeps after the sorts are done.
+func (e *Exporter) ScrapeTemp(ctx context.Context, conn *Config) error {
+	if conn.db == nil {
+		return nil
+	}
+	rows, err := conn.db.QueryContext(ctx, `SELECT h.tablespace_name, sum(h.bytes_used + h.bytes_free), sum(h.bytes_used),
+                                 nvl(max(s.used_blocks * t.block_size), 0), nvl(max(s.current_users), 0)
+                                 FROM v$temp_space_header h
+                                 JOIN dba_tablespaces t ON t.tablespace_name = h.tablespace_name
+                                 LEFT JOIN v$sort_segment s ON s.tablespace_name = h.tablespace_name
+                                 GROUP BY h.tablespace_name`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tablespace string
+		var total, allocated, used, users float64
+		if err := rows.Scan(&tablespace, &total, &allocated, &used, &users); err != nil {
+			return err
+		}
+		e.tempBytes.WithLabelValues(conn.Database, conn.Instance, tablespace, "total").Set(total)
+		e.tempBytes.WithLabelValues(conn.Database, conn.Instance, tablespace, "allocated").Set(allocated)
+		e.tempBytes.WithLabelValues(conn.Database, conn.Instance, tablespace, "used").Set(used)
+		e.tempBytes.WithLabelValues(conn.Database, conn.Instance, tablespace, "free").Set(total - used)
+		e.tempUsers.WithLabelValues(conn.Database, conn.Instance, tablespace).Set(users)
+	}
+	return rows.Err()
+}
+
+// ScrapeResourceLimit collects the utilization and the limits of processes, sessions,
+// transactions and parallel servers, to alert before ORA-00020 or ORA-00018.
+func (e *Exporter) ScrapeResourceLimit(ctx context.Context, conn *Config) error {
+	if conn.db == nil {
+		return nil
+	}
+	rows, err := conn.db.QueryContext(ctx, `SELECT resource_name, current_utilization, max_utilization, trim(limit_value)
+                                 FROM v$resource_limit
+                                 WHERE resource_name IN ('processes', 'sessions', 'transactions', 'parallel_max_servers')`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, limit string
+		var current, max float64
+		if err := rows.Scan(&name, &current, &max, &limit); err != nil {
+			return err
+		}
+		e.resLimit.WithLabelValues(conn.Database, conn.Instance, name, "current").Set(current)
+		e.resLimit.WithLabelValues(conn.Database, conn.Instance, name, "max").Set(max)
+		if value, err := strconv.ParseFloat(limit, 64); err == nil {
+			e.resLimit.WithLabelValues(conn.Database, conn.Instance, name, "limit").Set(value)
+		}
+	}
+	return rows.Err()
+}
+
+// ScrapeCursors collects the cursors held open by the exporter's own sessions, to catch
+// collectors or custom queries leaking cursors before they run into ORA-01000.
+func (e *Exporter) ScrapeCursors(ctx context.Context, conn *Config) error {
+	if conn.db == nil {
+		return nil
+	}
+	var value float64
+	err := conn.db.QueryRowContext(ctx, `SELECT nvl(sum(t.value), 0)
+                                 FROM v$sesstat t, v$statname n, v$session s
+                                 WHERE t.statistic# = n.statistic#
+                                 AND t.sid = s.sid
+                                 AND n.name = 'opened cursors current'
+                                 AND s.username = user`).Scan(&value)
+	if err != nil {
+		return err
+	}
+	e.cursors.WithLabelValues(conn.Database, conn.Instance).Set(value)
+	return nil
+}
+
+// ScrapePdbs collects the open mode of each PDB and the unresolved plug in violations, so
+// PDBs left mounted, restricted or violated after patching are caught. A non-CDB has no rows.
+func (e *Exporter) ScrapePdbs(ctx context.Context, conn *Config) error {
+	if conn.db == nil || conn.major() < 12 {
+		return nil
+	}
+	rows, err := conn.db.QueryContext(ctx, `SELECT name, con_id, open_mode, nvl(restricted, 'NO')
+                                 FROM v$pdbs`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, conID, mode, restricted string
+		if err := rows.Scan(&name, &conID, &mode, &restricted); err != nil {
+			break
+		}
+		open := 0.0
+		if (mode == "READ WRITE" || mode == "READ ONLY") && restricted != "YES" {
+			open = 1
+		}
+		e.pdbOpen.WithLabelValues(conn.Database, conn.Instance, name, conID, mode, restricted).Set(open)
+	}
+	rows.Close()
+
+	rows, err = conn.db.QueryContext(ctx, `SELECT v.name, nvl(to_char(p.con_id), ' '), v.type, count(*)
+                                 FROM pdb_plug_in_violations v, v$pdbs p
+                                 WHERE v.name = p.name(+)
+                                 AND v.status <> 'RESOLVED'
+                                 GROUP BY v.name, p.con_id, v.type`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, conID, typ string
+		var value float64
+		if err := rows.Scan(&name, &conID, &typ, &value); err != nil {
+			break
+		}
+		e.pdbViolate.WithLabelValues(conn.Database, conn.Instance, name, strings.TrimSpace(conID), typ).Set(value)
+	}
+	return nil
+}
+
+// ScrapeJobs collects the Data Pump and RMAN jobs with their age, to catch stuck jobs
+// holding locks and orphaned master tables of stopped or failed Data Pump jobs.
+func (e *Exporter) ScrapeJobs(ctx context.Context, conn *Config) error {
+	if conn.db == nil {
+		return nil
+	}
+	rows, err := conn.db.QueryContext(ctx, `SELECT j.owner_name, j.job_name, j.operation, j.state,
+                                        nvl((sysdate - o.created) * 86400, 0)
+                                 FROM dba_datapump_jobs j, dba_objects o
+                                 WHERE o.owner(+) = j.owner_name
+                                 AND o.object_name(+) = j.job_name
+                                 AND o.object_type(+) = 'TABLE'`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	states := make(map[string]float64)
+	for rows.Next() {
+		var owner, job, operation, state string
+		var age float64
+		if err := rows.Scan(&owner, &job, &operation, &state, &age); err != nil {
+			break
+		}
+		operation = strings.TrimSpace(operation)
+		states[state]++
+		e.dpJobAge.WithLabelValues(conn.Database, conn.Instance, owner, job, operation, state).Set(age)
+	}
+	rows.Close()
+	for state, n := range states {
+		e.dpJobs.WithLabelValues(conn.Database, conn.Instance, state).Set(n)
+	}
+
+	rows, err = conn.db.QueryContext(ctx, `SELECT operation, status, count(*),
+                                        nvl(max(CASE WHEN status LIKE 'RUNNING%' THEN (sysdate - start_time) * 86400 END), 0)
+                                 FROM v$rman_status
+                                 WHERE row_type = 'COMMAND'
+                                 AND (status LIKE 'RUNNING%' OR start_time > sysdate - 1)
+                                 GROUP BY operation, status`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	running := make(map[string]float64)
+	for rows.Next() {
+		var operation, status string
+		var value, age float64
+		if err := rows.Scan(&operation, &status, &value, &age); err != nil {
+			break
+		}
+		e.rmanJobs.WithLabelValues(conn.Database, conn.Instance, operation, status).Set(value)
+		if strings.HasPrefix(status, "RUNNING") && age > running[operation] {
+			running[operation] = age
 		}
 	}
+	for operation, age := range running {
+		e.rmanAge.WithLabelValues(conn.Database, conn.Instance, operation).Set(age)
+	}
+	return nil
+}
+
+// ScrapeClockSkew compares SYSTIMESTAMP and SYSDATE with the exporter host clock. A skewed
+// clock corrupts time based custom queries and the alert log timestamps.
+func (e *Exporter) ScrapeClockSkew(ctx context.Context, conn *Config) error {
+	if conn.db == nil {
+		return nil
+	}
+	var utc, local float64
+	t0 := time.Now()
+	err := conn.db.QueryRowContext(ctx, `select (cast(sys_extract_utc(systimestamp) as date) - date '1970-01-01')*86400 + mod(extract(second from systimestamp), 1),
+                                 (sysdate - date '1970-01-01')*86400
+                                 from dual`).Scan(&utc, &local)
+	if err != nil {
+		return err
+	}
+	// the database read its clock somewhere during the round trip, take the middle
+	now := t0.Add(time.Since(t0) / 2)
+	_, offset := now.Zone()
+	unix := float64(now.UnixNano()) / 1e9
+	e.clockskew.WithLabelValues(conn.Database, conn.Instance, "systimestamp").Set(utc - unix)
+	e.clockskew.WithLabelValues(conn.Database, conn.Instance, "sysdate").Set(local - unix - float64(offset))
+	return nil
 }
 
 // ScrapeTablespaces collects tablespace metrics
-func (e *Exporter) ScrapeTablespace(conn *Config) {
+func (e *Exporter) ScrapeTablespace(ctx context.Context, conn *Config) error {
 	var (
 		rows *sql.Rows
 		err  error
 	)
 	{
 		if conn.db != nil {
-			rows, err = conn.db.QueryContext(e.gctx, `WITH
+			rows, err = conn.db.QueryContext(ctx, `WITH
                                    getsize AS (SELECT tablespace_name, max(autoextensible) autoextensible, SUM(case autoextensible when 'YES' then maxbytes else bytes end) tsize, sum(user_bytes) tused
                                                FROM dba_data_files GROUP BY tablespace_name),
                                    getfree as (SELECT tablespace_name, contents, SUM(blocks*block_size) tfree
@@ -624,7 +2095,7 @@ func (e *Exporter) ScrapeTablespace(conn *Config) {
                                  FROM dba_temp_files
                                  GROUP BY tablespace_name`)
 			if err != nil {
-				return
+				return err
 			}
 			defer rows.Close()
 			for rows.Next() {
@@ -642,21 +2113,188 @@ func (e *Exporter) ScrapeTablespace(conn *Config) {
 			}
 		}
 	}
+	return nil
 }
 
-// ScrapeSessions collects session metrics from the v$session view.
-func (e *Exporter) ScrapeSession(conn *Config) {
-	var (
-		rows *sql.Rows
-		err  error
-	)
-	{
-		if conn.db != nil {
-			rows, err = conn.db.QueryContext(e.gctx, `SELECT decode(username,NULL,'SYSTEM','SYS','SYSTEM','USER'), status,count(*)
+// ScrapeDbSize collects the total size of the database, so capacity dashboards and
+// growth rates do not have to sum the series of all tablespaces.
+func (e *Exporter) ScrapeDbSize(ctx context.Context, conn *Config) error {
+	if conn.db == nil {
+		return nil
+	}
+	var allocated, used, temp, redo float64
+	err := conn.db.QueryRowContext(ctx, `SELECT (SELECT nvl(sum(bytes), 0) FROM dba_data_files),
+                                        (SELECT nvl(sum(bytes), 0) FROM dba_segments),
+                                        (SELECT nvl(sum(bytes), 0) FROM dba_temp_files),
+                                        (SELECT nvl(sum(bytes * members), 0) FROM v$log)
+                                 FROM dual`).Scan(&allocated, &used, &temp, &redo)
+	if err != nil {
+		return err
+	}
+	e.dbSize.WithLabelValues(conn.Database, conn.Instance, "allocated").Set(allocated)
+	e.dbSize.WithLabelValues(conn.Database, conn.Instance, "used").Set(used)
+	e.dbSize.WithLabelValues(conn.Database, conn.Instance, "temp").Set(temp)
+	e.dbSize.WithLabelValues(conn.Database, conn.Instance, "redo").Set(redo)
+	return nil
+}
+
+// ScrapeMemory collects the sizes of the SGA and its dynamic components and the PGA statistics
+// in bytes, like the aggregate target and the allocated and used PGA.
+func (e *Exporter) ScrapeMemory(ctx context.Context, conn *Config) error {
+	if conn.db == nil {
+		return nil
+	}
+	rows, err := conn.db.QueryContext(ctx, `select name, bytes from v$sgainfo`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var name string
+		var bytes float64
+		if err = rows.Scan(&name, &bytes); err != nil {
+			break
+		}
+		e.sgaBytes.WithLabelValues(conn.Database, conn.Instance, cleanName(name)).Set(bytes)
+	}
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	rows, err = conn.db.QueryContext(ctx, `select component, current_size, min_size, max_size from v$sga_dynamic_components`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var component string
+		var current, min, max float64
+		if err = rows.Scan(&component, &current, &min, &max); err != nil {
+			break
+		}
+		component = cleanName(component)
+		e.sgaComp.WithLabelValues(conn.Database, conn.Instance, component, "current").Set(current)
+		e.sgaComp.WithLabelValues(conn.Database, conn.Instance, component, "min").Set(min)
+		e.sgaComp.WithLabelValues(conn.Database, conn.Instance, component, "max").Set(max)
+	}
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	rows, err = conn.db.QueryContext(ctx, `select name, value from v$pgastat where unit = 'bytes'`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var name string
+		var value float64
+		if err = rows.Scan(&name, &value); err != nil {
+			break
+		}
+		e.pgaBytes.WithLabelValues(conn.Database, conn.Instance, cleanName(name)).Set(value)
+	}
+	rows.Close()
+	return err
+}
+
+// ScrapeAwr collects the retention and snapshot interval of the workload repository and the
+// age of the last snapshot of the instance, so a broken AWR collection is noticed before a
+// performance incident needs it. The dba_hist views need the Diagnostics Pack, they are
+// only read if control_management_pack_access enables it.
+func (e *Exporter) ScrapeAwr(ctx context.Context, conn *Config) error {
+	if conn.db == nil {
+		return nil
+	}
+	var access string
+	err := conn.db.QueryRowContext(ctx, `select value from v$parameter where name = 'control_management_pack_access'`).Scan(&access)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if !strings.Contains(strings.ToUpper(access), "DIAGNOSTIC") {
+		return nil
+	}
+	var retention, interval float64
+	err = conn.db.QueryRowContext(ctx, `select (sysdate + retention - sysdate) * 86400, (sysdate + snap_interval - sysdate) * 86400
+                                 from dba_hist_wr_control
+                                 where dbid = (select dbid from v$database)`).Scan(&retention, &interval)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil {
+		e.awrRetain.WithLabelValues(conn.Database, conn.Instance).Set(retention)
+		e.awrSnapInt.WithLabelValues(conn.Database, conn.Instance).Set(interval)
+	}
+	var age sql.NullFloat64
+	err = conn.db.QueryRowContext(ctx, `select (sysdate - cast(max(end_interval_time) as date)) * 86400
+                                 from dba_hist_snapshot
+                                 where dbid = (select dbid from v$database)
+                                 and instance_number = (select instance_number from v$instance)`).Scan(&age)
+	if err != nil {
+		return err
+	}
+	if age.Valid {
+		e.awrSnapAge.WithLabelValues(conn.Database, conn.Instance).Set(age.Float64)
+	}
+	return nil
+}
+
+// ScrapeDatafiles collects datafiles near their maxsize and counts datafile extensions from dba_data_files view.
+func (e *Exporter) ScrapeDatafiles(ctx context.Context, conn *Config) error {
+	var (
+		rows *sql.Rows
+		err  error
+	)
+	{
+		if conn.db != nil {
+			rows, err = conn.db.QueryContext(ctx, `select file_id, tablespace_name, bytes, maxbytes, autoextensible
+                                 from dba_data_files`)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+			near := make(map[string]float64)
+			key := conn.Database + "/" + conn.Instance
+			e.fileLok.Lock()
+			defer e.fileLok.Unlock()
+			for rows.Next() {
+				var id, name, auto string
+				var bytes, maxbytes float64
+				if err := rows.Scan(&id, &name, &bytes, &maxbytes, &auto); err != nil {
+					break
+				}
+				if auto == "YES" && maxbytes > 0 && bytes >= maxbytes*(1-*maxsizePct/100) {
+					near[name]++
+				} else {
+					near[name] += 0 // export 0 for tablespaces without such files
+				}
+
+				e.extensions.WithLabelValues(conn.Database, conn.Instance, name).Add(0)
+				if old, ok := e.fileBytes[key+"/"+id]; ok && bytes > old {
+					e.extensions.WithLabelValues(conn.Database, conn.Instance, name).Inc()
+				}
+				e.fileBytes[key+"/"+id] = bytes
+			}
+			for name, value := range near {
+				e.nearmaxsize.WithLabelValues(conn.Database, conn.Instance, name).Set(value)
+			}
+		}
+	}
+	return nil
+}
+
+// ScrapeSessions collects session metrics from the v$session view.
+func (e *Exporter) ScrapeSession(ctx context.Context, conn *Config) error {
+	var (
+		rows *sql.Rows
+		err  error
+	)
+	{
+		if conn.db != nil {
+			rows, err = conn.db.QueryContext(ctx, `SELECT decode(username,NULL,'SYSTEM','SYS','SYSTEM','USER'), status,count(*)
                                  FROM v$session
                                  GROUP BY decode(username,NULL,'SYSTEM','SYS','SYSTEM','USER'),status`)
 			if err != nil {
-				return
+				return err
 			}
 			defer rows.Close()
 			for rows.Next() {
@@ -670,34 +2308,127 @@ func (e *Exporter) ScrapeSession(conn *Config) {
 			}
 		}
 	}
+	return nil
 }
 
-// ScrapeUptime Instance uptime
-func (e *Exporter) ScrapeUptime(conn *Config) {
-	var uptime float64
+// ScrapeBlocking counts the blocking and blocked sessions and the longest wait of a blocked
+// session, so lock storms can be alerted on, and with -blocking.top the sessions blocked
+// per blocker with its sid and username.
+func (e *Exporter) ScrapeBlocking(ctx context.Context, conn *Config) error {
+	if conn.db == nil {
+		return nil
+	}
+	var blockers, blocked, maxWait float64
+	err := conn.db.QueryRowContext(ctx, `select count(distinct blocking_instance || '.' || blocking_session), count(*),
+                                 nvl(max(seconds_in_wait), 0)
+                                 from v$session
+                                 where blocking_session is not null`).Scan(&blockers, &blocked, &maxWait)
+	if err != nil {
+		return err
+	}
+	e.blocking.WithLabelValues(conn.Database, conn.Instance, "blocker").Set(blockers)
+	e.blocking.WithLabelValues(conn.Database, conn.Instance, "blocked").Set(blocked)
+	e.blockMax.WithLabelValues(conn.Database, conn.Instance).Set(maxWait)
+	if *blockingTop <= 0 {
+		return nil
+	}
+
+	// a blocker on another instance of a RAC has no username here
+	rows, err := conn.db.QueryContext(ctx, `select sid, username, value from (
+                                 select w.blocking_session sid, nvl(max(b.username), ' ') username, count(*) value
+                                 from v$session w
+                                 left join v$session b on b.sid = w.blocking_session and w.blocking_instance = userenv('instance')
+                                 where w.blocking_session is not null
+                                 group by w.blocking_session
+                                 order by count(*) desc)
+                                 where rownum <= :1`, *blockingTop)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sid, username string
+		var value float64
+		if err := rows.Scan(&sid, &username, &value); err != nil {
+			return err
+		}
+		e.blockers.WithLabelValues(conn.Database, conn.Instance, sid, strings.TrimSpace(username)).Set(value)
+	}
+	return rows.Err()
+}
+
+// ScrapeLongops collects the progress, the elapsed and the estimated remaining time of the
+// running long operations like RMAN backups, statistics gathering and full table scans.
+func (e *Exporter) ScrapeLongops(ctx context.Context, conn *Config) error {
+	if conn.db == nil {
+		return nil
+	}
+	rows, err := conn.db.QueryContext(ctx, `select opname, nvl(target, ' '), sid, nvl(username, ' '),
+                                 sofar / totalwork, elapsed_seconds, nvl(time_remaining, 0)
+                                 from v$session_longops
+                                 where totalwork > 0 and sofar < totalwork`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var opname, target, sid, username string
+		var progress, elapsed, remaining float64
+		if err := rows.Scan(&opname, &target, &sid, &username, &progress, &elapsed, &remaining); err != nil {
+			return err
+		}
+		target, username = strings.TrimSpace(target), strings.TrimSpace(username)
+		e.longopPct.WithLabelValues(conn.Database, conn.Instance, opname, target, sid, username).Set(progress)
+		e.longopTime.WithLabelValues(conn.Database, conn.Instance, opname, target, sid, username).Set(elapsed)
+		e.longopLeft.WithLabelValues(conn.Database, conn.Instance, opname, target, sid, username).Set(remaining)
+	}
+	return rows.Err()
+}
+
+// ScrapeUptime Instance uptime, startup time and restarts
+func (e *Exporter) ScrapeUptime(ctx context.Context, conn *Config) error {
+	var uptime, startup float64
+	var started string
 	{
 		if conn.db != nil {
-			err := conn.db.QueryRowContext(e.gctx, "select sysdate-startup_time from v$instance").Scan(&uptime)
+			err := conn.db.QueryRowContext(ctx, `select sysdate-startup_time,
+                                 to_char(startup_time, 'YYYY-MM-DD HH24:MI:SS'),
+                                 round((cast(sys_extract_utc(systimestamp) as date) - date '1970-01-01')*86400 - (sysdate-startup_time)*86400)
+                                 from v$instance`).Scan(&uptime, &started, &startup)
 			if err != nil {
-				return // ?
+				return err // ?
 			}
 			e.uptime.WithLabelValues(conn.Database, conn.Instance, conn.hostname).Set(uptime)
+			e.startup.WithLabelValues(conn.Database, conn.Instance).Set(startup)
+
+			key := conn.Database + "/" + conn.Instance
+			e.startupLok.Lock()
+			defer e.startupLok.Unlock()
+			if old, ok := e.startups[key]; ok && old != started {
+				log.Warnf("%s restarted at %s (previous startup %s)", key, started, old)
+				e.restarts.WithLabelValues(conn.Database, conn.Instance).Inc()
+			} else {
+				// expose the counter from the first scrape on, so increase() sees the first restart
+				e.restarts.WithLabelValues(conn.Database, conn.Instance).Add(0)
+			}
+			e.startups[key] = started
 		}
 	}
+	return nil
 }
 
 // ScrapeSysstat collects activity metrics from the v$sysstat view.
-func (e *Exporter) ScrapeSysstat(conn *Config) {
+func (e *Exporter) ScrapeSysstat(ctx context.Context, conn *Config) error {
 	var (
 		rows *sql.Rows
 		err  error
 	)
 	{
 		if conn.db != nil {
-			rows, err = conn.db.QueryContext(e.gctx, `SELECT name, value FROM v$sysstat
+			rows, err = conn.db.QueryContext(ctx, `SELECT name, value FROM v$sysstat
                                     WHERE statistic# in (6,7,1084,1089)`)
 			if err != nil {
-				return
+				return err
 			}
 			defer rows.Close()
 			for rows.Next() {
@@ -711,21 +2442,22 @@ func (e *Exporter) ScrapeSysstat(conn *Config) {
 			}
 		}
 	}
+	return nil
 }
 
 // ScrapeWaitTime collects wait time metrics from the v$waitclassmetric view.
-func (e *Exporter) ScrapeWaitclass(conn *Config) {
+func (e *Exporter) ScrapeWaitclass(ctx context.Context, conn *Config) error {
 	var (
 		rows *sql.Rows
 		err  error
 	)
 	{
 		if conn.db != nil {
-			rows, err = conn.db.QueryContext(e.gctx, `SELECT n.wait_class, round(m.time_waited/m.INTSIZE_CSEC,3)
+			rows, err = conn.db.QueryContext(ctx, `SELECT n.wait_class, round(m.time_waited/m.INTSIZE_CSEC,3)
                                     FROM v$waitclassmetric  m, v$system_wait_class n
                                     WHERE m.wait_class_id=n.wait_class_id and n.wait_class != 'Idle'`)
 			if err != nil {
-				return
+				return err
 			}
 			defer rows.Close()
 			for rows.Next() {
@@ -739,10 +2471,11 @@ func (e *Exporter) ScrapeWaitclass(conn *Config) {
 			}
 		}
 	}
+	return nil
 }
 
 // ScrapeSysmetrics collects session metrics from the v$sysmetrics view.
-func (e *Exporter) ScrapeSysmetric(conn *Config) {
+func (e *Exporter) ScrapeSysmetric(ctx context.Context, conn *Config) error {
 	var (
 		rows *sql.Rows
 		err  error
@@ -754,9 +2487,9 @@ func (e *Exporter) ScrapeSysmetric(conn *Config) {
 		//2100    Physical Write Total IO Requests Per Sec
 		//2124    Physical Write Total Bytes Per Sec
 		if conn.db != nil {
-			rows, err = conn.db.QueryContext(e.gctx, "select metric_name,value from v$sysmetric where metric_id in (2092,2093,2124,2100)")
+			rows, err = conn.db.QueryContext(ctx, "select metric_name,value from v$sysmetric where metric_id in (2092,2093,2124,2100)")
 			if err != nil {
-				return
+				return err
 			}
 			defer rows.Close()
 			for rows.Next() {
@@ -770,128 +2503,327 @@ func (e *Exporter) ScrapeSysmetric(conn *Config) {
 			}
 		}
 	}
+	return nil
 }
 
-// ScrapeTablerows collects bytes from dba_tables view.
-func (e *Exporter) ScrapeTablerows(conn *Config) {
+// ScrapeAAS collects the Average Active Sessions from the v$sysmetric view.
+func (e *Exporter) ScrapeAAS(ctx context.Context, conn *Config) error {
 	var (
 		rows *sql.Rows
 		err  error
 	)
 	{
+		//metric_id  metric_name
+		//2123    Database Time Per Sec  (centiseconds per second)
+		//2147    Average Active Sessions
 		if conn.db != nil {
-			rows, err = conn.db.QueryContext(e.gctx, `select owner,table_name, tablespace_name, num_rows
-                                 from dba_tables
-                                 where owner not like '%SYS%' and num_rows is not null`)
+			rows, err = conn.db.QueryContext(ctx, "select metric_id,value from v$sysmetric where group_id=2 and metric_id in (2123,2147)")
 			if err != nil {
-				return
+				return err
 			}
 			defer rows.Close()
+			aas, dbtime := -1.0, -1.0
 			for rows.Next() {
-				var owner string
-				var name string
-				var space string
+				var id int
 				var value float64
-				if err := rows.Scan(&owner, &name, &space, &value); err != nil {
+				if err := rows.Scan(&id, &value); err != nil {
 					break
 				}
-				name = cleanName(name)
-				e.tablerows.WithLabelValues(conn.Database, conn.Instance, owner, name, space).Set(value)
+				if id == 2147 {
+					aas = value
+				} else {
+					dbtime = value
+				}
+			}
+			if aas < 0 && dbtime >= 0 {
+				// older releases have no Average Active Sessions metric
+				aas = dbtime / 100
+			}
+			if aas >= 0 {
+				e.aas.WithLabelValues(conn.Database, conn.Instance).Set(aas)
 			}
 		}
 	}
+	return nil
 }
 
-func (e *Exporter) ScrapeTablebytes(conn *Config) {
-	// ScrapeTablebytes collects bytes from dba_tables/dba_segments view.
-	var (
-		rows *sql.Rows
-		err  error
-	)
-	{
-		if conn.db != nil {
-			rows, err = conn.db.QueryContext(e.gctx, `SELECT tab.owner, tab.table_name,  stab.bytes
+// ScrapeTablerows collects rows from dba_tables view.
+func (e *Exporter) ScrapeTablerows(ctx context.Context, conn *Config) error {
+	if conn.db != nil {
+		rows, err := e.pager("tablerows", conn).scan(ctx, conn.db, `select owner, table_name name, tablespace_name, num_rows value
+                                 from dba_tables
+                                 where owner not like '%SYS%' and num_rows is not null`, 1)
+		for _, r := range rows {
+			e.tablerows.WithLabelValues(conn.Database, conn.Instance, r.owner, cleanName(r.name), r.extra[0]).Set(r.value)
+		}
+		return err
+	}
+	return nil
+}
+
+// ScrapeTablebytes collects bytes from dba_tables/dba_segments view.
+func (e *Exporter) ScrapeTablebytes(ctx context.Context, conn *Config) error {
+	if conn.db != nil {
+		rows, err := e.pager("tablebytes", conn).scan(ctx, conn.db, `SELECT tab.owner, tab.table_name name, sum(stab.bytes) value
                                  FROM dba_tables  tab, dba_segments stab
                                  WHERE stab.owner = tab.owner AND stab.segment_name = tab.table_name
-                                 AND tab.owner NOT LIKE '%SYS%'`)
-			if err != nil {
-				return
-			}
-			defer rows.Close()
-			for rows.Next() {
-				var owner string
-				var name string
-				var value float64
-				if err = rows.Scan(&owner, &name, &value); err != nil {
-					break
-				}
-				name = cleanName(name)
-				e.tablebytes.WithLabelValues(conn.Database, conn.Instance, owner, name).Set(value)
-			}
+                                 AND tab.owner NOT LIKE '%SYS%'
+                                 GROUP BY tab.owner, tab.table_name`, 0)
+		for _, r := range rows {
+			e.tablebytes.WithLabelValues(conn.Database, conn.Instance, r.owner, cleanName(r.name)).Set(r.value)
+		}
+		return err
+	}
+	return nil
+}
+
+// ScrapeIndexbytes collects bytes from dba_indexes/dba_segments view.
+func (e *Exporter) ScrapeIndexbytes(ctx context.Context, conn *Config) error {
+	if conn.db != nil {
+		rows, err := e.pager("indexbytes", conn).scan(ctx, conn.db, `select table_owner owner, table_name name, sum(bytes) value
+                                 from dba_indexes ind, dba_segments seg
+                                 WHERE ind.owner=seg.owner and ind.index_name=seg.segment_name
+                                 and table_owner NOT LIKE '%SYS%'
+                                 group by table_owner,table_name`, 0)
+		for _, r := range rows {
+			e.indexbytes.WithLabelValues(conn.Database, conn.Instance, r.owner, cleanName(r.name)).Set(r.value)
 		}
+		return err
 	}
+	return nil
 }
 
-// ScrapeTablebytes collects bytes from dba_indexes/dba_segments view.
-func (e *Exporter) ScrapeIndexbytes(conn *Config) {
+// ScrapeLobbytes collects bytes from dba_lobs/dba_segments view.
+func (e *Exporter) ScrapeLobbytes(ctx context.Context, conn *Config) error {
+	if conn.db != nil {
+		rows, err := e.pager("lobbytes", conn).scan(ctx, conn.db, `select l.owner, l.table_name name, sum(bytes) value
+                                 from dba_lobs l, dba_segments seg
+                                 WHERE l.owner=seg.owner and l.table_name=seg.segment_name
+                                 and l.owner NOT LIKE '%SYS%'
+                                 group by l.owner,l.table_name`, 0)
+		for _, r := range rows {
+			e.lobbytes.WithLabelValues(conn.Database, conn.Instance, r.owner, cleanName(r.name)).Set(r.value)
+		}
+		return err
+	}
+	return nil
+}
+
+// ScrapeObjectchanges counts objects changed by DDL per owner from dba_objects view.
+func (e *Exporter) ScrapeObjectchanges(ctx context.Context, conn *Config) error {
 	var (
 		rows *sql.Rows
 		err  error
 	)
 	{
 		if conn.db != nil {
-			rows, err = conn.db.QueryContext(e.gctx, `select table_owner,table_name, sum(bytes)
-                                 from dba_indexes ind, dba_segments seg
-                                 WHERE ind.owner=seg.owner and ind.index_name=seg.segment_name
-                                 and table_owner NOT LIKE '%SYS%'
-                                 group by table_owner,table_name`)
+			rows, err = conn.db.QueryContext(ctx, `select owner, count(*)
+                                 from dba_objects
+                                 where last_ddl_time > sysdate - :1/24
+                                 and owner not like '%SYS%'
+                                 group by owner`, *objChangeHrs)
 			if err != nil {
-				return
+				return err
 			}
 			defer rows.Close()
 			for rows.Next() {
 				var owner string
-				var name string
 				var value float64
-				if err = rows.Scan(&owner, &name, &value); err != nil {
+				if err = rows.Scan(&owner, &value); err != nil {
 					break
 				}
-				name = cleanName(name)
-				e.indexbytes.WithLabelValues(conn.Database, conn.Instance, owner, name).Set(value)
+				e.objchanges.WithLabelValues(conn.Database, conn.Instance, owner).Set(value)
 			}
 		}
 	}
+	return nil
 }
 
-// ScrapeLobbytes collects bytes from dba_lobs/dba_segments view.
-func (e *Exporter) ScrapeLobbytes(conn *Config) {
+// ScrapeUserstats collects the statistics of -userstats.stats from v$sesstat summed per
+// username, limited to the -userstats.top users per statistic.
+func (e *Exporter) ScrapeUserstats(ctx context.Context, conn *Config) error {
 	var (
 		rows *sql.Rows
 		err  error
 	)
+	var binds []interface{}
+	var marks []string
+	for _, stat := range strings.Split(*userStatList, ",") {
+		if stat = strings.TrimSpace(stat); stat != "" {
+			binds = append(binds, stat)
+			marks = append(marks, fmt.Sprintf(":%d", len(binds)))
+		}
+	}
+	if len(binds) == 0 {
+		return nil
+	}
 	{
 		if conn.db != nil {
-			rows, err = conn.db.QueryContext(e.gctx, `select l.owner, l.table_name, sum(bytes)
-                                 from dba_lobs l, dba_segments seg
-                                 WHERE l.owner=seg.owner and l.table_name=seg.segment_name
-                                 and l.owner NOT LIKE '%SYS%'
-                                 group by l.owner,l.table_name`)
+			rows, err = conn.db.QueryContext(ctx, `select username, name, value from (
+                                 select s.username, n.name, sum(t.value) value,
+                                        row_number() over (partition by n.name order by sum(t.value) desc) rn
+                                 from v$sesstat t, v$statname n, v$session s
+                                 where t.statistic# = n.statistic#
+                                 and t.sid = s.sid
+                                 and s.username is not null
+                                 and n.name in (`+strings.Join(marks, ",")+`)
+                                 group by s.username, n.name)
+                                 where rn <= `+fmt.Sprintf(":%d", len(binds)+1), append(binds, *userStatTop)...)
 			if err != nil {
-				return
+				return err
 			}
 			defer rows.Close()
 			for rows.Next() {
-				var owner string
-				var name string
+				var username, name string
 				var value float64
-				if err = rows.Scan(&owner, &name, &value); err != nil {
+				if err = rows.Scan(&username, &name, &value); err != nil {
 					break
 				}
-				name = cleanName(name)
-				e.lobbytes.WithLabelValues(conn.Database, conn.Instance, owner, name).Set(value)
+				e.userstat.WithLabelValues(conn.Database, conn.Instance, username, cleanName(name)).Set(value)
+			}
+		}
+	}
+	return nil
+}
+
+// ScrapeSecurity counts the failed logons of the last -security.hours from the unified audit
+// trail if unified auditing is enabled, else from dba_audit_trail, and the accounts locked
+// in that window.
+func (e *Exporter) ScrapeSecurity(ctx context.Context, conn *Config) error {
+	if conn.db == nil {
+		return nil
+	}
+	// no row before 12c, which has no unified audit trail
+	var unified string
+	err := conn.db.QueryRowContext(ctx, `select value from v$option where parameter = 'Unified Auditing'`).Scan(&unified)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	// failed logons have a return code, e.g. 1017 invalid password or 28000 account locked
+	trail := `select userid username, returncode, count(*) value
+                                 from dba_audit_trail
+                                 where action_name = 'LOGON' and returncode != 0
+                                 and timestamp > sysdate - :1/24
+                                 group by userid, returncode`
+	unifiedTrail := `select dbusername username, return_code returncode, count(*) value
+                                 from unified_audit_trail
+                                 where action_name = 'LOGON' and return_code != 0
+                                 and event_timestamp > systimestamp - numtodsinterval(:1, 'HOUR')
+                                 group by dbusername, return_code`
+	args := []interface{}{*securityHrs, *securityTop}
+	top := ":2"
+	switch unified {
+	case "TRUE":
+		trail = unifiedTrail
+	case "FALSE":
+		// mixed mode: unified policies like ORA_LOGON_FAILURES write to the unified trail
+		// besides the traditional one, a logon audited by both is counted once
+		trail = `select username, returncode, max(value) value from (` + trail + `
+                                 union all ` + strings.Replace(unifiedTrail, ":1", ":2", 1) + `)
+                                 group by username, returncode`
+		args = []interface{}{*securityHrs, *securityHrs, *securityTop}
+		top = ":3"
+	}
+	rows, err := conn.db.QueryContext(ctx, `select username, returncode, value from (
+                                 select t.*, row_number() over (order by value desc) rn from (`+trail+`) t)
+                                 where rn <= `+top, args...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var username sql.NullString
+		var code, value float64
+		if err = rows.Scan(&username, &code, &value); err != nil {
+			break
+		}
+		e.failLogons.WithLabelValues(conn.Database, conn.Instance, username.String, fmt.Sprintf("ORA-%05.0f", code)).Set(value)
+	}
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	var locked float64
+	err = conn.db.QueryRowContext(ctx, `select count(*) from dba_users
+                                 where lock_date > sysdate - :1/24`, *securityHrs).Scan(&locked)
+	if err != nil {
+		return err
+	}
+	e.lockouts.WithLabelValues(conn.Database, conn.Instance).Set(locked)
+	return nil
+}
+
+// ScrapeDebugSql exports the most expensive statements parsed by the exporter's user with
+// their sql_id, to find the slow query of a collector in a debug scrape.
+func (e *Exporter) ScrapeDebugSql(ctx context.Context, conn *Config) error {
+	if conn.db == nil {
+		return nil
+	}
+	rows, err := conn.db.QueryContext(ctx, `select sql_id, sql_text, elapsed from (
+                                 select sql_id, substr(min(sql_text), 1, 80) sql_text, sum(elapsed_time)/1000000 elapsed
+                                 from v$sql
+                                 where parsing_schema_name = user
+                                 group by sql_id
+                                 order by 3 desc)
+                                 where rownum <= 20`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, text string
+		var elapsed float64
+		if err = rows.Scan(&id, &text, &elapsed); err != nil {
+			return err
+		}
+		e.debugSql.WithLabelValues(conn.Database, conn.Instance, id, strings.Join(strings.Fields(text), " ")).Set(elapsed)
+	}
+	return rows.Err()
+}
+
+// ScrapeWatched samples the active sessions of the watch list of conn and their current
+// wait events, focused metrics for one application without full session level collectors.
+func (e *Exporter) ScrapeWatched(ctx context.Context, conn *Config) error {
+	if conn.db == nil {
+		return nil
+	}
+	for _, w := range conn.Watch {
+		username, module := w.Username, w.Module
+		if username == "" {
+			username = "%"
+		}
+		if module == "" {
+			module = "%"
+		}
+		rows, err := conn.db.QueryContext(ctx, `select username, nvl(module, ' '),
+                                 decode(state, 'WAITING', wait_class, 'CPU'),
+                                 decode(state, 'WAITING', event, 'ON CPU'),
+                                 count(*), sum(decode(state, 'WAITING', wait_time_micro, 0))/1000000
+                                 from v$session
+                                 where status = 'ACTIVE' and type = 'USER'
+                                 and username like :1 and nvl(module, ' ') like :2
+                                 group by username, nvl(module, ' '),
+                                          decode(state, 'WAITING', wait_class, 'CPU'),
+                                          decode(state, 'WAITING', event, 'ON CPU')`, username, module)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var user, mod, class, event string
+			var count, wait float64
+			if err = rows.Scan(&user, &mod, &class, &event, &count, &wait); err != nil {
+				break
 			}
+			mod = strings.TrimSpace(mod)
+			e.watched.WithLabelValues(conn.Database, conn.Instance, user, mod, class, event).Set(count)
+			e.watchWait.WithLabelValues(conn.Database, conn.Instance, user, mod, class, event).Set(wait)
+		}
+		rows.Close()
+		if err != nil {
+			return err
 		}
 	}
+	return nil
 }
 
 // Describe describes all the metrics exported by the Oracle exporter.
@@ -899,65 +2831,135 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	e.duration.Describe(ch)
 	e.totalScrapes.Describe(ch)
 	e.scrapeErrors.Describe(ch)
+	e.durations.Describe(ch)
 	e.session.Describe(ch)
 	e.sysstat.Describe(ch)
 	e.waitclass.Describe(ch)
 	e.sysmetric.Describe(ch)
+	e.aas.Describe(ch)
 	e.interconnect.Describe(ch)
 	e.tablespace.Describe(ch)
+	e.nearmaxsize.Describe(ch)
+	e.extensions.Describe(ch)
 	e.recovery.Describe(ch)
 	e.redo.Describe(ch)
 	e.cache.Describe(ch)
 	e.uptime.Describe(ch)
+	e.startup.Describe(ch)
+	e.restarts.Describe(ch)
 	e.up.Describe(ch)
+	e.connRetries.Describe(ch)
+	e.driverInfo.Describe(ch)
+	e.identFallbacks.Describe(ch)
 	e.alertlog.Describe(ch)
 	e.alertdate.Describe(ch)
 	e.services.Describe(ch)
 	e.parameter.Describe(ch)
+	e.component.Describe(ch)
+	e.directory.Describe(ch)
+	e.exttables.Describe(ch)
+	e.patch.Describe(ch)
+	e.patchdate.Describe(ch)
+	e.paramstate.Describe(ch)
+	e.paramchanges.Describe(ch)
 	//e.query.Describe(ch)
 	e.asmspace.Describe(ch)
+	e.location.Describe(ch)
+	e.healthy.Describe(ch)
+	e.locFree.Describe(ch)
+	e.tempundo.Describe(ch)
+	e.tempseg.Describe(ch)
+	e.cursors.Describe(ch)
+	e.clockskew.Describe(ch)
+	e.pdbOpen.Describe(ch)
+	e.pdbViolate.Describe(ch)
+	e.dpJobs.Describe(ch)
+	e.dpJobAge.Describe(ch)
+	e.rmanJobs.Describe(ch)
+	e.rmanAge.Describe(ch)
+	e.dbSize.Describe(ch)
+	e.awrRetain.Describe(ch)
+	e.awrSnapInt.Describe(ch)
+	e.awrSnapAge.Describe(ch)
+	e.sgaBytes.Describe(ch)
+	e.sgaComp.Describe(ch)
+	e.pgaBytes.Describe(ch)
+	e.dgLag.Describe(ch)
+	e.dgRate.Describe(ch)
+	e.dgDestLag.Describe(ch)
+	e.dgStatus.Describe(ch)
+	e.dgMember.Describe(ch)
+	e.fsfoStatus.Describe(ch)
+	e.fsfoObsrv.Describe(ch)
+	e.archDest.Describe(ch)
+	e.archErr.Describe(ch)
+	e.archGap.Describe(ch)
+	e.blocking.Describe(ch)
+	e.blockMax.Describe(ch)
+	e.blockers.Describe(ch)
+	e.enqReqs.Describe(ch)
+	e.enqWaits.Describe(ch)
+	e.enqWaitSec.Describe(ch)
+	e.longopPct.Describe(ch)
+	e.longopTime.Describe(ch)
+	e.longopLeft.Describe(ch)
+	e.undoBytes.Describe(ch)
+	e.undoSnapTO.Describe(ch)
+	e.undoMaxQry.Describe(ch)
+	e.tempBytes.Describe(ch)
+	e.tempUsers.Describe(ch)
+	e.resLimit.Describe(ch)
+	e.asmDiskOps.Describe(ch)
+	e.asmDiskErr.Describe(ch)
+	e.asmDiskUp.Describe(ch)
+	e.asmDgBytes.Describe(ch)
+	e.asmRebPwr.Describe(ch)
+	e.asmRebLeft.Describe(ch)
 	e.tablerows.Describe(ch)
 	e.tablebytes.Describe(ch)
 	e.indexbytes.Describe(ch)
 	e.lobbytes.Describe(ch)
+	e.objchanges.Describe(ch)
+	e.userstat.Describe(ch)
+	e.watched.Describe(ch)
+	e.failLogons.Describe(ch)
+	e.debugTime.Describe(ch)
+	e.debugRows.Describe(ch)
+	e.debugSql.Describe(ch)
+	e.lockouts.Describe(ch)
+	e.watchWait.Describe(ch)
+	e.oemUp.Describe(ch)
+	e.oemMetric.Describe(ch)
+	e.aqMessages.Describe(ch)
+	e.aqAge.Describe(ch)
+	e.skippedCol.Describe(ch)
+	e.collectorOff.Describe(ch)
+	ch <- e.scrapeOpts
+	e.customCount.Describe(ch)
+	e.seriesCapped.Describe(ch)
+	e.configGen.Describe(ch)
+	e.sharedScrapes.Describe(ch)
 	for _, metric := range e.custom {
 		metric.Describe(ch)
 	}
 }
 
-func (e *Exporter) resetAllMetrics() {
-	// e.used_times.Reset()
-	e.up.Reset()
-
-	e.session.Reset()
-	e.sysstat.Reset()
-	e.waitclass.Reset()
-	e.sysmetric.Reset()
-	e.interconnect.Reset()
-	e.tablespace.Reset()
-	e.recovery.Reset()
-	e.redo.Reset()
-	e.cache.Reset()
-	e.uptime.Reset()
-	e.alertlog.Reset()
-	e.alertdate.Reset()
-	e.services.Reset()
-	e.parameter.Reset()
-	//e.query.Reset()
-	e.asmspace.Reset()
-	e.tablerows.Reset()
-	e.tablebytes.Reset()
-	e.indexbytes.Reset()
-	e.lobbytes.Reset()
-
-	for _, metric := range e.custom {
-		metric.Reset()
-	}
+// scrapeSet returns an Exporter sharing the state of e which fills a fresh metricSet
+// with opts.
+func (e *Exporter) scrapeSet(opts *scrapeOptions) *Exporter {
+	set := newMetricSet()
+	set.opts = opts
+	return &Exporter{exporterState: e.exporterState, metricSet: set}
 }
 
 // Connect the DBs and gather Databasename and Instancename
 // first time or connect breaked , will on next 2 time reconnect
 func (e *Exporter) Connect() chan *Config {
+	return e.connectContext(context.Background())
+}
+
+// connectContext is Connect waiting for the connects at most until ctx is done.
+func (e *Exporter) connectContext(ctx context.Context) chan *Config {
 	backConnStep1 := make(chan int)
 	go e.execConn(testConnStepAll)
 	go e.backConnect(backConnStep1, backConnStepAll)
@@ -976,7 +2978,7 @@ func (e *Exporter) Connect() chan *Config {
 
 	// wait a second, or all connect active finished
 	//  just end all connected, close chan
-	timeout, cancel := context.WithTimeout(context.Background(), time.Duration(3)*time.Second)
+	timeout, cancel := context.WithTimeout(ctx, time.Duration(3)*time.Second)
 	defer cancel()
 	select {
 	case <-backConnStep1:
@@ -986,6 +2988,29 @@ func (e *Exporter) Connect() chan *Config {
 	return openedConn
 }
 
+// connectTarget is Connect for the target name (database or instance) only, so a scrape
+// of /metrics/<database> does not wait for the connects of the others.
+func (e *Exporter) connectTarget(ctx context.Context, name string) chan *Config {
+	openedConn := make(chan *Config, 1)
+	defer close(openedConn)
+	conf := findTarget(name)
+	if conf == nil {
+		return openedConn
+	}
+	if conf.db != nil {
+		var x int
+		if err := conf.db.QueryRowContext(ctx, "select 1 as X from dual").Scan(&x); err != nil {
+			e.connect(conf)
+		}
+	} else {
+		e.connect(conf)
+	}
+	if conf.db != nil {
+		openedConn <- conf
+	}
+	return openedConn
+}
+
 func (e *Exporter) backConnect(connStep1 chan<- int, connStepAll chan int) {
 	// skip if already run this
 	select {
@@ -1016,42 +3041,8 @@ func (e *Exporter) backConnect(connStep1 chan<- int, connStepAll chan int) {
 
 		wg.Add(1)
 		go func(conf *Config) {
-			conf.db = nil
-			defer func() {
-				wg.Done()
-				log.Infoln("connect to", conf.Connection, " status:", conf.db != nil)
-			}()
-
-			if len(conf.Connection) > 0 {
-				db, err := sql.Open("oracle", conf.Connection)
-				if err == nil {
-					err = db.Ping()
-					if err != nil {
-						return
-					}
-					conf.db = db
-
-					var dbname, inname, hostname string
-					err = conf.db.QueryRow("select db_unique_name,instance_name,host_name from v$database,v$instance").Scan(&dbname, &inname, &hostname)
-					if err == nil {
-						if (len(conf.Database) == 0) || (len(conf.Instance) == 0) {
-							conf.Database = dbname
-							conf.Instance = inname
-						}
-						conf.hostname = hostname
-						e.up.WithLabelValues(conf.Database, conf.Instance, conf.hostname).Set(1)
-					} else {
-						conf.db.Close()
-						conf.db = nil
-						e.up.WithLabelValues(conf.Database, conf.Instance, conf.hostname).Set(0)
-						log.Errorln("Error connecting to database:", err)
-						//log.Infoln("Connect OK, Inital query failed: ", conf.Connection)
-					}
-				}
-			} else {
-				//log.Infoln("Dummy Connection: ", conf.Database)
-				e.up.WithLabelValues(conf.Database, conf.Instance, conf.hostname).Set(0)
-			}
+			defer wg.Done()
+			e.connect(conf)
 		}(&config.Cfgs[i])
 	}
 	cfgLok.Unlock()
@@ -1060,24 +3051,195 @@ func (e *Exporter) backConnect(connStep1 chan<- int, connStepAll chan int) {
 	wg.Wait()
 }
 
-func splitConnStr(str string) (string, string) {
-	ipport := "??"
-	svname := "???"
+// transientError reports whether a connect error is worth a retry: the listener or the
+// network was briefly unavailable, as opposed to e.g. a wrong password.
+func transientError(err error) bool {
+	var nerr net.Error
+	if errors.As(err, &nerr) {
+		return true
+	}
+	msg := err.Error()
+	for _, s := range []string{"ORA-12170", "ORA-12541", "connection refused", "i/o timeout", "connection reset"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
 
-	sts := strings.Split(str, "@")
-	if len(sts) >= 2 {
-		ips := strings.Split(sts[1], "/")
-		if len(ips) >= 2 {
-			ipport = ips[0]
-			dbs := strings.Split(ips[1], "?")
-			if len(dbs) >= 1 {
-				svname = dbs[0]
+// connect opens the connection of one target and resolves its database/instance names.
+func (e *Exporter) connect(conf *Config) {
+	conf.db = nil
+	var cerr error
+	defer func() {
+		log.Infoln("connect to", conf.Connection, " status:", conf.db != nil)
+		conf.connected(cerr)
+	}()
+
+	if len(conf.Connection) > 0 {
+		dsn, err := conf.dsn()
+		if err != nil {
+			cerr = err
+			e.up.WithLabelValues(conf.Database, conf.Instance, conf.hostname).Set(0)
+			log.Errorln("Error connecting to database", conf.Database+":", err)
+			return
+		}
+		db := openDB(dsn, conf.InitSQL)
+		{
+			err = db.Ping()
+			for retry, wait := 1, *connBackoff; err != nil && transientError(err) && retry <= *connRetryMax; retry, wait = retry+1, wait*2 {
+				log.Warnf("connect to %s/%s failed: %v, retry %d in %s", conf.Database, conf.Instance, err, retry, wait)
+				time.Sleep(wait)
+				e.connRetries.WithLabelValues(conf.Database, conf.Instance).Inc()
+				err = db.Ping()
+			}
+			if err != nil {
+				cerr = err
+				// the pool of a failed connect is not used, the next connect opens a new one
+				db.Close()
+				if strings.Contains(err.Error(), "ORA-01017") {
+					// invalid username/password, the secret may have been rotated
+					forgetCredentials(conf)
+				}
+				return
+			}
+			conf.db = db
+
+			dbname, inname, hostname, version, err := e.identify(conf)
+			if err == nil {
+				if (len(conf.Database) == 0) || (len(conf.Instance) == 0) {
+					conf.Database = dbname
+					conf.Instance = inname
+				}
+				conf.hostname = hostname
+				conf.version = version
+				// the series of failed connects before the host was known
+				e.up.DeleteLabelValues(conf.Database, conf.Instance, "")
+				e.up.WithLabelValues(conf.Database, conf.Instance, conf.hostname).Set(1)
+				e.setDriverInfo(conf)
+			} else {
+				cerr = err
+				conf.db.Close()
+				conf.db = nil
+				e.up.WithLabelValues(conf.Database, conf.Instance, conf.hostname).Set(0)
+				log.Errorln("Error connecting to database:", err)
+				//log.Infoln("Connect OK, Inital query failed: ", conf.Connection)
 			}
 		}
+	} else {
+		//log.Infoln("Dummy Connection: ", conf.Database)
+		cerr = errors.New("no connection configured")
+		e.up.WithLabelValues(conf.Database, conf.Instance, conf.hostname).Set(0)
+	}
+}
+
+// identify returns the names, host and version of the database conf is connected to.
+// Accounts granted only some views may not read v$instance or v$database, the names then
+// come from sys_context and the version from product_component_version.
+func (e *Exporter) identify(conf *Config) (dbname, inname, hostname, version string, err error) {
+	fallback := func(view string, err error) {
+		log.Warnf("%s/%s: %s: %v, using sys_context", conf.Database, conf.Instance, view, err)
+		e.identFallbacks.WithLabelValues(conf.Database, conf.Instance, view).Inc()
 	}
+	err = conf.db.QueryRow("select instance_name,host_name,version from v$instance").Scan(&inname, &hostname, &version)
+	if err != nil {
+		fallback("v$instance", err)
+		var host sql.NullString
+		err = conf.db.QueryRow("select sys_context('USERENV','INSTANCE_NAME'), sys_context('USERENV','SERVER_HOST') from dual").Scan(&inname, &host)
+		if err != nil {
+			return
+		}
+		hostname = host.String
+		// the version is optional, major() returns 0 without it
+		conf.db.QueryRow("select version from product_component_version where product like 'Oracle%' and rownum = 1").Scan(&version)
+	}
+	err = conf.db.QueryRow("select db_unique_name from v$database").Scan(&dbname)
+	if err != nil && strings.Contains(err.Error(), "ORA-01507") {
+		// database not mounted: ASM or NOMOUNT instance, connected with role
+		return inname, inname, hostname, version, nil
+	}
+	if err != nil {
+		fallback("v$database", err)
+		err = conf.db.QueryRow("select coalesce(sys_context('USERENV','DB_UNIQUE_NAME'), sys_context('USERENV','DB_NAME')) from dual").Scan(&dbname)
+	}
+	return
+}
+
+// setDriverInfo exports the driver info of a new connection of conf.
+func (e *Exporter) setDriverInfo(conf *Config) {
+	ctx, cancel := context.WithTimeout(context.Background(), conf.scrapeTimeout())
+	defer cancel()
+	d, err := connDriverInfo(ctx, conf.db)
+	if err != nil {
+		log.Warnln("driver info of", conf.Database+":", err)
+		return
+	}
+	if conf.driver != nil {
+		e.driverInfo.DeleteLabelValues(conf.driver.labels(conf)...)
+	}
+	conf.driver = d
+	e.driverInfo.WithLabelValues(d.labels(conf)...).Set(1)
+}
+
+// splitConnStr returns the ipport and svname labels of a connection: host:port of the first
+// address and the service name (or SID) of a go-ora URL, an easy connect string, a connect
+// descriptor or a tnsnames.ora alias. An alias which can not be resolved is used as both.
+func splitConnStr(str string) (string, string) {
+	if v, ok := connLabels.Load(str); ok {
+		l := v.([2]string)
+		return l[0], l[1]
+	}
+	ipport, svname := parseConnStr(str)
+	connLabels.Store(str, [2]string{ipport, svname})
 	return ipport, svname
 }
 
+// connLabels caches splitConnStr, it runs for every series of a scrape.
+var connLabels sync.Map
+
+func parseConnStr(str string) (string, string) {
+	if strings.Contains(str, "://") {
+		if u, err := url.Parse(str); err == nil && u.Host != "" {
+			a := connAddress{host: u.Hostname(), port: u.Port(), service: strings.TrimPrefix(u.Path, "/"), sid: u.Query().Get("SID")}
+			return a.hostPort(), firstOf(a.service, a.sid, "???")
+		}
+		str = str[strings.Index(str, "://")+3:]
+	}
+	target := str
+	if i := strings.LastIndex(str, "@"); i >= 0 {
+		target = str[i+1:]
+	}
+	var a connAddress
+	switch {
+	case target == "":
+		return "??", "???"
+	case strings.HasPrefix(target, "("):
+		a = parseDescriptor(target)
+	case strings.Contains(target, "/"):
+		a = parseEasyConnect(target)
+	default:
+		desc, err := tnsDescriptor(target)
+		if err != nil {
+			return target, target
+		}
+		a = parseDescriptor(desc)
+	}
+	if a.host == "" {
+		return "??", firstOf(a.service, a.sid, "???")
+	}
+	return a.hostPort(), firstOf(a.service, a.sid, "???")
+}
+
+// firstOf returns the first non-empty value.
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
 func CloseConnection(oldconfig Configs) {
 	for _, v := range oldconfig.Cfgs {
 		go func(conf Config) {
@@ -1095,9 +3257,21 @@ func CloseConnection(oldconfig Configs) {
 	}
 }
 
-// Collect implements prometheus.Collector.
+// Collect implements prometheus.Collector, scraping with the options of the flags.
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
-	e.resetAllMetrics()
+	e.collect(ch, flagOptions())
+}
+
+// collect scrapes all connections with opts and sends the metrics to ch.
+func (e *Exporter) collect(ch chan<- prometheus.Metric, opts *scrapeOptions) {
+	if !beginScrape() {
+		return
+	}
+	defer scrapes.Done()
+	s := e.scrapeSet(opts)
+	if opts.remoteIP != "" {
+		log.Debugln("scrape by", opts.remoteIP)
+	}
 
 	var err error
 
@@ -1115,13 +3289,24 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 	ch <- e.totalScrapes
 	ch <- e.error
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(*timeout))
-	e.gctx = ctx
+	budget := time.Second * time.Duration(*timeout)
+	if opts.timeout > 0 {
+		// Prometheus gives up after its scrape_timeout
+		budget = opts.timeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), budget)
 	defer cancel()
 
-	openedConn := e.Connect()
+	var openedConn chan *Config
+	if opts.target != "" {
+		openedConn = e.connectTarget(ctx, opts.target)
+	} else {
+		openedConn = e.connectContext(ctx)
+	}
 	ii := cap(openedConn)
 	var wg sync.WaitGroup
+	scraped := make(map[*Config]bool)
+	var scrapedLok sync.Mutex
 
 ForLoop:
 	for i := 0; i < ii; i++ {
@@ -1143,154 +3328,546 @@ ForLoop:
 			continue
 		}
 
-		ipport, svname := splitConnStr(conn1.Connection)
 		if conn1.db == nil {
 			continue
 		}
 
 		wg.Add(1)
 		go func(conn1 *Config) {
-			t0 := time.Now()
-			defer func() {
-				wg.Done()
-				t1 := time.Now()
-				ipport, svname := splitConnStr(conn1.Connection)
-				e.used_times.WithLabelValues(ipport, svname, "scrape_total").Set(t1.Sub(t0).Seconds())
-			}()
-
-			var t time.Time
-			t = time.Now()
-			if e.vRecovery || *pRecovery {
-				e.ScrapeRecovery(conn1)
+			defer wg.Done()
+			ctx := ctx
+			if conn1.Timeout > 0 {
+				// e.g. a standby over WAN, may take longer than the other targets
+				t := conn1.scrapeTimeout()
+				if opts.timeout > 0 && opts.timeout < t {
+					t = opts.timeout
+				}
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(context.Background(), t)
+				defer cancel()
 			}
-			e.used_times.WithLabelValues(ipport, svname, "ScrapeRecovery").Set(time.Since(t).Seconds())
+			s.scrapeConn(ctx, conn1)
+			scrapedLok.Lock()
+			scraped[conn1] = true
+			scrapedLok.Unlock()
+		}(conn1)
 
-			t = time.Now()
-			if *pMetrics {
-				e.ScrapeUptime(conn1)
-				e.ScrapeSession(conn1)
-				e.ScrapeSysstat(conn1)
-				e.ScrapeWaitclass(conn1)
-				e.ScrapeSysmetric(conn1)
-				e.ScrapeTablespace(conn1)
-				e.ScrapeInterconnect(conn1)
-				e.ScrapeRedo(conn1)
-				e.ScrapeCache(conn1)
-				//e.ScrapeAlertlog(conn1)  // TODO
-				e.ScrapeServices(conn1)
-				e.ScrapeParameter(conn1)
-				e.ScrapeAsmspace(conn1)
-			}
-			e.used_times.WithLabelValues(ipport, svname, "pMetrics").Set(time.Since(t).Seconds())
+	}
+	wg.Wait()
+	s.evalHealth(scraped)
 
-			t = time.Now()
-			e.ScrapeCustomQueries(conn1)
-			e.used_times.WithLabelValues(ipport, svname, "ScrapeCustomQueries").Set(time.Since(t).Seconds())
+	if opts.full() {
+		e.lastLok.Lock()
+		e.prev, e.last = e.last, s.metricSet
+		e.lastLok.Unlock()
+	}
 
-			//e.ScrapeQuery()
-			t = time.Now()
-			if e.vTabRows || *pTabRows {
-				e.ScrapeTablerows(conn1)
-			}
-			e.used_times.WithLabelValues(ipport, svname, "ScrapeTablerows").Set(time.Since(t).Seconds())
+	s.collectMetrics(ch)
+}
 
-			t = time.Now()
-			if e.vTabBytes || *pTabBytes {
-				e.ScrapeTablebytes(conn1)
-			}
-			e.used_times.WithLabelValues(ipport, svname, "ScrapeTablebytes").Set(time.Since(t).Seconds())
+// scrape runs one collector for conn, unless it was disabled for this target because
+// its views do not exist (ORA-00942, missing grant or feature) in disableAfter scrapes.
+func (e *Exporter) scrape(ctx context.Context, conn *Config, collector string, f func(context.Context, *Config) error) {
+	if !conn.collectorEnabled(collector) || !e.opts.collects(collector) || conn.Heavy.runs(collector) != e.opts.heavy {
+		return
+	}
+	if e.isDisabled(conn, collector) {
+		return
+	}
 
-			t = time.Now()
-			if e.vIndBytes || *pIndBytes {
-				e.ScrapeIndexbytes(conn1)
-			}
-			e.used_times.WithLabelValues(ipport, svname, "ScrapeIndexbytes").Set(time.Since(t).Seconds())
+	t0 := time.Now()
+	err := f(ctx, conn)
+	e.durations.WithLabelValues(conn.Database, conn.Instance, collector).Observe(time.Since(t0).Seconds())
+	if err != nil {
+		e.scrapeErrors.WithLabelValues(collector).Inc()
+		conn.scrapeFailed(collector, err)
+	}
+	if e.opts.debug {
+		e.debugTime.WithLabelValues(conn.Database, conn.Instance, collector).Set(time.Since(t0).Seconds())
+	}
+	e.countMissing(conn, collector, err)
+}
 
-			t = time.Now()
-			if e.vLobBytes || *pLobBytes {
-				e.ScrapeLobbytes(conn1)
-			}
-			e.used_times.WithLabelValues(ipport, svname, "ScrapeLobbytes").Set(time.Since(t).Seconds())
+// scrapeOptional runs one of the optionalCollectors if it is enabled for this scrape.
+func (e *Exporter) scrapeOptional(ctx context.Context, conn *Config, collector string, f func(context.Context, *Config) error) {
+	if e.opts.optional[collector] {
+		e.scrape(ctx, conn, collector, f)
+	}
+}
 
-		}(conn1)
+// isDisabled reports whether name, a collector or custom/<query>, is disabled for conn.
+func (e *Exporter) isDisabled(conn *Config, name string) bool {
+	e.disabledLok.Lock()
+	defer e.disabledLok.Unlock()
+	return *disableAfter > 0 && e.disabled[conn.Database+"/"+conn.Instance+"/"+name] >= *disableAfter
+}
 
+// countMissing counts the consecutive ORA-00942 errors of name for conn and disables it
+// after disableAfter of them.
+func (e *Exporter) countMissing(conn *Config, name string, err error) {
+	key := conn.Database + "/" + conn.Instance + "/" + name
+	e.disabledLok.Lock()
+	defer e.disabledLok.Unlock()
+	if err == nil || !strings.Contains(err.Error(), "ORA-00942") {
+		delete(e.disabled, key)
+		return
 	}
-	wg.Wait()
+	e.disabled[key]++
+	if *disableAfter > 0 && e.disabled[key] >= *disableAfter {
+		log.Warnf("%s/%s collector %s disabled after %d times: %v", conn.Database, conn.Instance, name, e.disabled[key], err)
+		e.collectorOff.WithLabelValues(conn.Database, conn.Instance, name).Set(1)
+	}
+}
 
-	{
+// configLoaded updates the exporter after the config file was (re)loaded.
+func (e *Exporter) configLoaded() {
+	addCustomsql(e)
+	e.enableCollectors()
+	// aliases may resolve differently with a changed tnsnames.ora
+	connLabels.Range(func(key, _ interface{}) bool {
+		connLabels.Delete(key)
+		return true
+	})
+}
 
-		if e.vRecovery || *pRecovery {
-			e.recovery.Collect(ch)
-		}
+// enableCollectors enables all collectors disabled by scrape again, e.g. after a config reload.
+func (e *Exporter) enableCollectors() {
+	e.disabledLok.Lock()
+	defer e.disabledLok.Unlock()
+	e.disabled = make(map[string]int)
+	e.collectorOff.Reset()
+}
 
-		if *pMetrics {
-			e.uptime.Collect(ch)
-			e.session.Collect(ch)
-			e.sysstat.Collect(ch)
-			e.waitclass.Collect(ch)
-			e.sysmetric.Collect(ch)
-			e.tablespace.Collect(ch)
-			e.interconnect.Collect(ch)
-			e.redo.Collect(ch)
-			e.cache.Collect(ch)
-			//e.alertlog.Collect(ch)
-			//e.alertdate.Collect(ch)
-			e.services.Collect(ch)
-			e.parameter.Collect(ch)
-			e.asmspace.Collect(ch)
-		}
+// collectorNames are the collectors run by scrapeConn, usable in the collectors section of a connection.
+var collectorNames = []string{"recovery", "uptime", "session", "sysstat", "waitclass", "sysmetric", "aas",
+	"tablespace", "datafiles", "interconnect", "redo", "cache", "services", "parameter", "parameterchanges",
+	"components", "directories", "patch", "asmspace", "locations", "tempundo", "cursors", "clockskew", "custom",
+	"tablerows", "tablebytes", "indexbytes", "lobbytes", "objectchanges", "userstats", "security", "watch", "oem",
+	"alertlog", "pdbs", "jobs", "dbsize", "aq", "awr", "memory", "dataguard", "dgbroker", "archivedest", "blocking", "enqueue", "longops", "undo", "temp", "resource"}
 
-		for _, metric := range e.custom {
-			metric.Collect(ch)
-		}
-		//e.query.Collect(ch)
-		if e.vTabRows || *pTabRows {
-			e.tablerows.Collect(ch)
-		}
-		if e.vTabBytes || *pTabBytes {
-			e.tablebytes.Collect(ch)
-		}
-		if e.vIndBytes || *pIndBytes {
-			e.indexbytes.Collect(ch)
+// scrapeConn runs all enabled collectors against one connection.
+func (e *Exporter) scrapeConn(ctx context.Context, conn1 *Config) {
+	ipport, svname := splitConnStr(conn1.Connection)
+	t0 := time.Now()
+	conn1.setStatus(func(s *targetStatus) { s.scrapeErrors = nil })
+	defer func() {
+		if !e.opts.heavy {
+			e.used_times.WithLabelValues(ipport, svname, "scrape_total").Set(time.Since(t0).Seconds())
 		}
-		if e.vLobBytes || *pLobBytes {
-			e.lobbytes.Collect(ch)
+		conn1.setStatus(func(s *targetStatus) {
+			s.lastScrape, s.scrapeSeconds = t0, time.Since(t0).Seconds()
+		})
+	}()
+
+	var t time.Time
+	t = time.Now()
+	if e.opts.recovery {
+		e.scrape(ctx, conn1, "recovery", e.ScrapeRecovery)
+	}
+	e.used_times.WithLabelValues(ipport, svname, "ScrapeRecovery").Set(time.Since(t).Seconds())
+
+	t = time.Now()
+	if e.opts.defaultMetrics {
+		e.scrape(ctx, conn1, "uptime", e.ScrapeUptime)
+		e.scrape(ctx, conn1, "session", e.ScrapeSession)
+		e.scrape(ctx, conn1, "sysstat", e.ScrapeSysstat)
+		e.scrape(ctx, conn1, "waitclass", e.ScrapeWaitclass)
+		e.scrape(ctx, conn1, "sysmetric", e.ScrapeSysmetric)
+		e.scrape(ctx, conn1, "aas", e.ScrapeAAS)
+		e.scrape(ctx, conn1, "tablespace", e.ScrapeTablespace)
+		e.scrape(ctx, conn1, "datafiles", e.ScrapeDatafiles)
+		e.scrape(ctx, conn1, "interconnect", e.ScrapeInterconnect)
+		e.scrape(ctx, conn1, "redo", e.ScrapeRedo)
+		e.scrape(ctx, conn1, "cache", e.ScrapeCache)
+		e.scrape(ctx, conn1, "alertlog", e.ScrapeAlertlog)
+		e.scrape(ctx, conn1, "services", e.ScrapeServices)
+		e.scrape(ctx, conn1, "parameter", e.ScrapeParameter)
+		e.scrape(ctx, conn1, "components", e.ScrapeComponents)
+		e.scrape(ctx, conn1, "directories", e.ScrapeDirectories)
+		e.scrape(ctx, conn1, "patch", e.ScrapePatch)
+		e.scrape(ctx, conn1, "asmspace", e.ScrapeAsmspace)
+		e.scrape(ctx, conn1, "locations", e.ScrapeLocations)
+		e.scrape(ctx, conn1, "tempundo", e.ScrapeTempUndo)
+		e.scrape(ctx, conn1, "cursors", e.ScrapeCursors)
+		e.scrape(ctx, conn1, "clockskew", e.ScrapeClockSkew)
+	}
+	e.used_times.WithLabelValues(ipport, svname, "pMetrics").Set(time.Since(t).Seconds())
+
+	t = time.Now()
+	e.scrapeOptional(ctx, conn1, "parameterchanges", e.ScrapeParameterChanges)
+	e.scrapeOptional(ctx, conn1, "pdbs", e.ScrapePdbs)
+	e.scrapeOptional(ctx, conn1, "jobs", e.ScrapeJobs)
+	e.scrapeOptional(ctx, conn1, "dbsize", e.ScrapeDbSize)
+	e.scrapeOptional(ctx, conn1, "awr", e.ScrapeAwr)
+	e.scrapeOptional(ctx, conn1, "memory", e.ScrapeMemory)
+	e.scrapeOptional(ctx, conn1, "dataguard", e.ScrapeDataguard)
+	e.scrapeOptional(ctx, conn1, "dgbroker", e.ScrapeDgBroker)
+	e.scrapeOptional(ctx, conn1, "archivedest", e.ScrapeArchiveDest)
+	e.scrapeOptional(ctx, conn1, "blocking", e.ScrapeBlocking)
+	e.scrapeOptional(ctx, conn1, "enqueue", e.ScrapeEnqueue)
+	e.scrapeOptional(ctx, conn1, "longops", e.ScrapeLongops)
+	e.scrapeOptional(ctx, conn1, "undo", e.ScrapeUndo)
+	e.scrapeOptional(ctx, conn1, "temp", e.ScrapeTemp)
+	e.scrapeOptional(ctx, conn1, "resource", e.ScrapeResourceLimit)
+	e.used_times.WithLabelValues(ipport, svname, "optional").Set(time.Since(t).Seconds())
+
+	t = time.Now()
+	e.scrape(ctx, conn1, "custom", e.ScrapeCustomQueries)
+	e.used_times.WithLabelValues(ipport, svname, "ScrapeCustomQueries").Set(time.Since(t).Seconds())
+
+	//e.ScrapeQuery()
+	t = time.Now()
+	if e.opts.tableRows {
+		e.scrape(ctx, conn1, "tablerows", e.ScrapeTablerows)
+	}
+	e.used_times.WithLabelValues(ipport, svname, "ScrapeTablerows").Set(time.Since(t).Seconds())
+
+	t = time.Now()
+	if e.opts.tableBytes {
+		e.scrape(ctx, conn1, "tablebytes", e.ScrapeTablebytes)
+	}
+	e.used_times.WithLabelValues(ipport, svname, "ScrapeTablebytes").Set(time.Since(t).Seconds())
+
+	t = time.Now()
+	if e.opts.indexBytes {
+		e.scrape(ctx, conn1, "indexbytes", e.ScrapeIndexbytes)
+	}
+	e.used_times.WithLabelValues(ipport, svname, "ScrapeIndexbytes").Set(time.Since(t).Seconds())
+
+	t = time.Now()
+	if e.opts.lobBytes {
+		e.scrape(ctx, conn1, "lobbytes", e.ScrapeLobbytes)
+	}
+	e.used_times.WithLabelValues(ipport, svname, "ScrapeLobbytes").Set(time.Since(t).Seconds())
+
+	t = time.Now()
+	if e.opts.objectChanges {
+		e.scrape(ctx, conn1, "objectchanges", e.ScrapeObjectchanges)
+	}
+	e.used_times.WithLabelValues(ipport, svname, "ScrapeObjectchanges").Set(time.Since(t).Seconds())
+
+	t = time.Now()
+	if e.opts.userStats {
+		e.scrape(ctx, conn1, "userstats", e.ScrapeUserstats)
+	}
+	e.used_times.WithLabelValues(ipport, svname, "ScrapeUserstats").Set(time.Since(t).Seconds())
+
+	t = time.Now()
+	if e.opts.security {
+		e.scrape(ctx, conn1, "security", e.ScrapeSecurity)
+	}
+	e.used_times.WithLabelValues(ipport, svname, "ScrapeSecurity").Set(time.Since(t).Seconds())
+
+	if e.opts.debug {
+		// not a collector, a debug scrape shows it whatever the collectors of conn1 are
+		if err := e.ScrapeDebugSql(ctx, conn1); err != nil {
+			log.Warnln("debug scrape", conn1.Database, err)
 		}
 	}
 
+	t = time.Now()
+	if len(conn1.Watch) > 0 {
+		e.scrape(ctx, conn1, "watch", e.ScrapeWatched)
+	}
+	e.used_times.WithLabelValues(ipport, svname, "ScrapeWatched").Set(time.Since(t).Seconds())
+
+	t = time.Now()
+	if conn1.Oem != nil {
+		e.scrape(ctx, conn1, "oem", e.ScrapeOem)
+	}
+	e.used_times.WithLabelValues(ipport, svname, "ScrapeOem").Set(time.Since(t).Seconds())
+
+	t = time.Now()
+	if conn1.Aq != nil {
+		e.scrape(ctx, conn1, "aq", e.ScrapeAq)
+	}
+	e.used_times.WithLabelValues(ipport, svname, "ScrapeAq").Set(time.Since(t).Seconds())
+}
+
+// collectMetrics sends the current content of all enabled metric vectors to ch.
+func (e *Exporter) collectMetrics(ch chan<- prometheus.Metric) {
+	e.metricSet.collectVectors(ch)
+	e.collectHeavy(ch, e.opts)
+
+	if e.opts.defaultMetrics {
+		e.restarts.Collect(ch)
+		e.extensions.Collect(ch)
+	}
+	if e.opts.optional["parameterchanges"] {
+		e.paramchanges.Collect(ch)
+	}
+	e.customCount.Collect(ch)
+	e.seriesCapped.Collect(ch)
+	ch <- e.configGen
+	ch <- e.sharedScrapes
 	e.scrapeErrors.Collect(ch)
+	e.collectorOff.Collect(ch)
+	e.durations.Collect(ch)
+	e.up.Collect(ch)
+	e.connRetries.Collect(ch)
+	e.driverInfo.Collect(ch)
+	e.identFallbacks.Collect(ch)
 	e.used_times.Collect(ch)
+
+	ch <- prometheus.MustNewConstMetric(e.scrapeOpts, prometheus.GaugeValue, 1,
+		strconv.FormatBool(e.opts.defaultMetrics),
+		strconv.FormatBool(e.opts.recovery),
+		strconv.FormatBool(e.opts.tableRows),
+		strconv.FormatBool(e.opts.tableBytes),
+		strconv.FormatBool(e.opts.indexBytes),
+		strconv.FormatBool(e.opts.lobBytes),
+		strconv.FormatBool(e.opts.objectChanges))
 }
 
+// collectVectors sends the metric vectors of the set enabled by its options to ch.
+func (e *metricSet) collectVectors(ch chan<- prometheus.Metric) {
+	if e.opts.recovery {
+		e.recovery.Collect(ch)
+	}
+
+	if e.opts.defaultMetrics {
+		e.uptime.Collect(ch)
+		e.startup.Collect(ch)
+		e.session.Collect(ch)
+		e.sysstat.Collect(ch)
+		e.waitclass.Collect(ch)
+		e.sysmetric.Collect(ch)
+		e.aas.Collect(ch)
+		e.tablespace.Collect(ch)
+		e.nearmaxsize.Collect(ch)
+		e.interconnect.Collect(ch)
+		e.redo.Collect(ch)
+		e.cache.Collect(ch)
+		e.alertlog.Collect(ch)
+		e.alertdate.Collect(ch)
+		e.services.Collect(ch)
+		e.parameter.Collect(ch)
+		e.component.Collect(ch)
+		e.directory.Collect(ch)
+		e.exttables.Collect(ch)
+		e.patch.Collect(ch)
+		e.patchdate.Collect(ch)
+		e.asmspace.Collect(ch)
+		e.location.Collect(ch)
+		e.locFree.Collect(ch)
+		e.tempundo.Collect(ch)
+		e.tempseg.Collect(ch)
+		e.cursors.Collect(ch)
+		e.clockskew.Collect(ch)
+		e.asmDiskOps.Collect(ch)
+		e.asmDiskErr.Collect(ch)
+		e.asmDiskUp.Collect(ch)
+		e.asmDgBytes.Collect(ch)
+		e.asmRebPwr.Collect(ch)
+		e.asmRebLeft.Collect(ch)
+	}
+
+	// the vectors of the optional collectors are empty unless they ran
+	e.paramstate.Collect(ch)
+	e.pdbOpen.Collect(ch)
+	e.pdbViolate.Collect(ch)
+	e.dpJobs.Collect(ch)
+	e.dpJobAge.Collect(ch)
+	e.rmanJobs.Collect(ch)
+	e.rmanAge.Collect(ch)
+	e.dbSize.Collect(ch)
+	e.awrRetain.Collect(ch)
+	e.awrSnapInt.Collect(ch)
+	e.awrSnapAge.Collect(ch)
+	e.sgaBytes.Collect(ch)
+	e.sgaComp.Collect(ch)
+	e.pgaBytes.Collect(ch)
+	e.dgLag.Collect(ch)
+	e.dgRate.Collect(ch)
+	e.dgDestLag.Collect(ch)
+	e.dgStatus.Collect(ch)
+	e.dgMember.Collect(ch)
+	e.fsfoStatus.Collect(ch)
+	e.fsfoObsrv.Collect(ch)
+	e.archDest.Collect(ch)
+	e.archErr.Collect(ch)
+	e.archGap.Collect(ch)
+	e.blocking.Collect(ch)
+	e.blockMax.Collect(ch)
+	e.blockers.Collect(ch)
+	e.enqReqs.Collect(ch)
+	e.enqWaits.Collect(ch)
+	e.enqWaitSec.Collect(ch)
+	e.longopPct.Collect(ch)
+	e.longopTime.Collect(ch)
+	e.longopLeft.Collect(ch)
+	e.undoBytes.Collect(ch)
+	e.undoSnapTO.Collect(ch)
+	e.undoMaxQry.Collect(ch)
+	e.tempBytes.Collect(ch)
+	e.tempUsers.Collect(ch)
+	e.resLimit.Collect(ch)
+
+	for _, metric := range e.custom {
+		metric.Collect(ch)
+	}
+	e.skippedCol.Collect(ch)
+	e.healthy.Collect(ch)
+	//e.query.Collect(ch)
+	if e.opts.tableRows {
+		e.tablerows.Collect(ch)
+	}
+	if e.opts.tableBytes {
+		e.tablebytes.Collect(ch)
+	}
+	if e.opts.indexBytes {
+		e.indexbytes.Collect(ch)
+	}
+	if e.opts.lobBytes {
+		e.lobbytes.Collect(ch)
+	}
+	if e.opts.objectChanges {
+		e.objchanges.Collect(ch)
+	}
+	if e.opts.userStats {
+		e.userstat.Collect(ch)
+	}
+	if e.opts.security {
+		e.failLogons.Collect(ch)
+		e.lockouts.Collect(ch)
+	}
+	if e.opts.debug {
+		e.debugTime.Collect(ch)
+		e.debugRows.Collect(ch)
+		e.debugSql.Collect(ch)
+	}
+	e.watched.Collect(ch)
+	e.watchWait.Collect(ch)
+	e.oemUp.Collect(ch)
+	e.oemMetric.Collect(ch)
+	e.aqMessages.Collect(ch)
+	e.aqAge.Collect(ch)
+}
+
+// Handler serves the metrics, scraped with the options of the request.
 func (e *Exporter) Handler(w http.ResponseWriter, r *http.Request) {
-	e.lastIp = ""
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err == nil {
-		e.lastIp = ip
+	opts, err := requestOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var conn *Config
+	if opts.target != "" {
+		if conn = findTarget(opts.target); conn == nil {
+			http.Error(w, "unknown target "+opts.target, http.StatusNotFound)
+			return
+		}
+	}
+	reg := prometheus.NewRegistry()
+	prometheus.WrapRegistererWith(constLabels, reg).MustRegister(e.withOptions(opts))
+	g := targetLabels(prometheus.Gatherers{prometheus.DefaultGatherer, reg})
+	if e.guard != nil {
+		// the result is shared with waiting requests, the labels are only changed once
+		g = prometheus.Gatherers{prometheus.DefaultGatherer, e.guard.gatherer(r.Context(), opts, targetLabels(reg), e.sharedScrapes)}
+	}
+	if conn != nil {
+		g = onlyTarget(g, conn)
 	}
-	e.vTabRows = false
-	e.vTabBytes = false
-	e.vIndBytes = false
-	e.vLobBytes = false
-	e.vRecovery = false
-	if r.URL.Query().Get("tablerows") == "true" {
-		e.vTabRows = true
+	promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, metricsHandler(g)).ServeHTTP(w, r)
+}
+
+// ScrapeNowHandler runs all collectors of a single target out of band, including its heavy
+// tier whose result replaces the one exported by the scrapes, and writes the resulting
+// series of that target, to verify a fix without waiting for the next scrape or run.
+// It works on a copy of the connection, a connection opened for it is closed afterwards.
+func (e *Exporter) ScrapeNowHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
 	}
-	if r.URL.Query().Get("tablebytes") == "true" {
-		e.vTabBytes = true
+	opts, err := requestOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	if r.URL.Query().Get("indexbytes") == "true" {
-		e.vIndBytes = true
+	target := r.URL.Query().Get("target")
+	c, ok := copyTarget(target)
+	if !ok {
+		http.Error(w, "unknown target "+target, http.StatusNotFound)
+		return
 	}
-	if r.URL.Query().Get("lobbytes") == "true" {
-		e.vLobBytes = true
+	conn := &c
+	if conn.db == nil {
+		e.connect(conn)
+		if conn.db != nil {
+			defer conn.db.Close()
+		}
+	}
+	if conn.db == nil {
+		http.Error(w, "target "+target+" is not connected", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), conn.scrapeTimeout())
+	defer cancel()
+	s := e.scrapeSet(opts)
+	s.scrapeConn(ctx, conn)
+	heavy := e.scrapeHeavyNow(r.Context(), conn)
+
+	reg := prometheus.NewRegistry()
+	prometheus.WrapRegistererWith(constLabels, reg).MustRegister(collectorFunc(func(ch chan<- prometheus.Metric) {
+		s.collectMetrics(ch)
+		if heavy != nil {
+			heavy.collectVectors(ch)
+		}
+	}))
+	mfs, err := targetLabels(reg).Gather()
+	if err != nil {
+		log.Warnln("scrapeNow gather:", err)
+	}
+
+	ipport, svname := splitConnStr(conn.Connection)
+	w.Header().Set("Content-Type", string(expfmt.FmtText))
+	for _, mf := range mfs {
+		metrics := mf.Metric[:0]
+		for _, m := range mf.Metric {
+			if ofTarget(m, conn.Database, ipport, svname) {
+				metrics = append(metrics, m)
+			}
+		}
+		if len(metrics) == 0 {
+			continue
+		}
+		mf.Metric = metrics
+		expfmt.MetricFamilyToText(w, mf)
+	}
+}
+
+// ofInstance reports whether m has no dbinstance label or the given one.
+func ofInstance(m *dto.Metric, instance string) bool {
+	for _, lp := range m.GetLabel() {
+		if lp.GetName() == "dbinstance" {
+			return lp.GetValue() == instance
+		}
+	}
+	return true
+}
+
+// ofTarget reports whether m belongs to the target with the given database or ipport/svname.
+func ofTarget(m *dto.Metric, database, ipport, svname string) bool {
+	labels := make(map[string]string)
+	for _, lp := range m.GetLabel() {
+		labels[lp.GetName()] = lp.GetValue()
 	}
-	if r.URL.Query().Get("recovery") == "true" {
-		e.vRecovery = true
+	if db, ok := labels["database"]; ok {
+		return db == database
 	}
-	promhttp.Handler().ServeHTTP(w, r)
+	return labels["ipport"] == ipport && labels["svname"] == svname
 }
 
+// collectorFunc turns a function into an unchecked prometheus.Collector.
+type collectorFunc func(ch chan<- prometheus.Metric)
+
+func (f collectorFunc) Describe(ch chan<- *prometheus.Desc) {}
+
+func (f collectorFunc) Collect(ch chan<- prometheus.Metric) { f(ch) }
+
 func main() {
 	log.SetLevel(log.InfoLevel)
 	customFormatter := new(log.TextFormatter)
@@ -1312,76 +3889,186 @@ func main() {
 		}
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "testconn" {
+		// subcommand form, e.g. testconn --format=json -configfile oracle.conf
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		*testconn = true
+	}
+	if len(os.Args) > 1 && os.Args[1] == "rules" {
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		*rules = true
+	}
 	flag.Parse()
+	if *grants || *testconn || *rules || *textFile == "-" {
+		// keep stdout clean for the script or the metrics
+		log.SetOutput(os.Stderr)
+	}
+
+	if *encryptPass {
+		if err := printEncrypted(os.Stdin, os.Stdout); err != nil {
+			log.Fatalf("error: %v", err)
+		}
+		return
+	}
+	if *checkCfg {
+		if !checkConfig(os.Stdout, *configFile) {
+			os.Exit(1)
+		}
+		return
+	}
 
 	log.Infoln("Starting Prometheus Oracle exporter " + Version)
-	if loadConfig() {
-		if *testconn {
-			log.Infoln(" run testConnects ", len(config.Cfgs))
-			testConnects()
+	if constLabels, err = parseLabels(*globalLabels); err != nil {
+		log.Fatalf("error: -labels: %v", err)
+	}
+	if err := validNamespace(*namespaceFlag); err != nil {
+		log.Fatalf("error: -namespace: %v", err)
+	}
+	if err := validLabelMaxLen(*labelMaxLen); err != nil {
+		log.Fatalf("error: -label.max-length: %v", err)
+	}
+	if optionalEnabled, err = parseOptional(*optCollect); err != nil {
+		log.Fatalf("error: -collectors.optional: %v", err)
+	}
+	if err := loadConfig(); err != nil {
+		log.Fatalf("error: %v", err)
+	}
+	if *testconn {
+		if !testConnects(os.Stdout, *testFormat) {
+			os.Exit(1)
+		}
+		return
+	}
+	if *grants {
+		printGrants(os.Stdout)
+		return
+	}
+	if *rules {
+		// collectors after the flags restrict the rules like collect[] of the scrapes
+		o := flagOptions()
+		if names := flag.Args(); len(names) > 0 {
+			if err := o.restrict(names); err != nil {
+				log.Fatalf("error: rules: %v", err)
+			}
+		}
+		if err := printRules(os.Stdout, o); err != nil {
+			log.Fatalf("error: %v", err)
+		}
+		return
+	}
+
+	processOpenFiles()
+
+	log.Infoln("Config loaded: ", *configFile)
+	exporter := NewExporter()
+	// -labels may not collide with the labels of any metric of the exporter
+	if err := prometheus.WrapRegistererWith(constLabels, prometheus.NewRegistry()).Register(exporter); err != nil {
+		log.Fatalf("error: -labels: %v", err)
+	}
+	go watchTargets(exporter)
+	go runHeavy(exporter)
+	// the first connects, /readyz would wait for the first scrape otherwise
+	go exporter.Connect()
+	stopped := make(chan struct{})
+	if *textFile != "" {
+		go shutdownOnSignal(nil, stopped)
+		go runTextfile(exporter)
+		<-stopped
+		return
+	}
+	// a dedicated mux, the DefaultServeMux may carry handlers registered by imported packages
+	mux := http.NewServeMux()
+	admin := strings.TrimRight(*adminPrefix, "/")
+
+	log.Infoln("List http routes:")
+	log.Infoln(" ", *metricPath)
+	mux.HandleFunc(*metricPath, exporter.Handler)
+	log.Infoln(" ", *metricPath+"/<database>")
+	mux.HandleFunc(strings.TrimRight(*metricPath, "/")+"/", exporter.Handler)
+
+	log.Infoln("  /healthz, /readyz")
+	mux.HandleFunc("/healthz", HealthzHandler)
+	mux.HandleFunc("/readyz", ReadyzHandler)
+
+	log.Infoln("  /    show index")
+	mux.HandleFunc("/", LandingHandler)
+
+	log.Infoln(" ", admin+"/showConfig")
+	mux.HandleFunc(admin+"/showConfig", func(w http.ResponseWriter, r *http.Request) {
+		writeEffectiveConfig(w)
+	})
+
+	log.Infoln(" ", admin+"/reloadConfig (POST)")
+	mux.HandleFunc(admin+"/reloadConfig", adminHandler(func(w http.ResponseWriter, r *http.Request) {
+		if err := loadConfig(); err != nil {
+			log.Errorln("reload Config by", r.RemoteAddr, "failed:", err)
+			http.Error(w, "loadConfig failed, the running config is kept: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		log.Infoln("reload Config by", r.RemoteAddr)
+		exporter.configLoaded()
+		writeEffectiveConfig(w)
+	}))
 
-		processOpenFiles()
+	log.Infoln(" ", admin+"/config (GET, POST)")
+	mux.HandleFunc(admin+"/config", exporter.ConfigHandler)
 
-		log.Infoln("Config loaded: ", *configFile)
-		exporter := NewExporter()
-		prometheus.MustRegister(exporter)
+	log.Infoln(" ", admin+"/alerts?target=X&since=1h")
+	mux.HandleFunc(admin+"/alerts", exporter.AlertsHandler)
 
-		log.Infoln("List http routes:")
-		log.Infoln(" ", *metricPath)
-		http.HandleFunc(*metricPath, exporter.Handler)
+	log.Infoln(" ", admin+"/status")
+	mux.HandleFunc(admin+"/status", exporter.StatusHandler)
 
-		log.Infoln("  /    show index")
-		http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) { w.Write(landingPage) })
+	log.Infoln(" ", admin+"/targets")
+	mux.HandleFunc(admin+"/targets", TargetsHandler)
 
-		log.Infoln("  /showConfig")
-		http.HandleFunc("/showConfig", func(w http.ResponseWriter, r *http.Request) {
-			{
-				w.Header().Add("Type", "application/json")
-				bts, _ := json.MarshalIndent(config, "", "\t")
-				w.Write([]byte(bts))
-			}
-		})
+	log.Infoln(" ", admin+"/debug/diff?target=X")
+	mux.HandleFunc(admin+"/debug/diff", exporter.DiffHandler)
 
-		log.Infoln("  /reloadConfig")
-		http.HandleFunc("/reloadConfig", func(w http.ResponseWriter, r *http.Request) {
-			reload := loadConfig()
-			log.Infoln("reload Config, ", reload)
-			if reload {
-				addCustomsql(exporter)
-				w.Header().Add("Type", "application/json")
-				bts, _ := json.MarshalIndent(config, "", "\t")
-				w.Write([]byte(bts))
-			} else {
-				w.Write([]byte(fmt.Sprintf(" loadConfig: %v", reload)))
-			}
-		})
+	log.Infoln(" ", admin+"/scrapeNow?target=X (POST)")
+	mux.HandleFunc(admin+"/scrapeNow", exporter.ScrapeNowHandler)
 
-		log.Infoln("  /getTimeout")
-		http.HandleFunc("/getTimeout", func(w http.ResponseWriter, r *http.Request) {
-			w.Write([]byte("current timeout=" + strconv.Itoa(*timeout)))
-		})
+	log.Infoln(" ", admin+"/getTimeout")
+	mux.HandleFunc(admin+"/getTimeout", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("current timeout=" + strconv.Itoa(*timeout)))
+	})
 
-		log.Infoln("  /setTimeout?v=10")
-		http.HandleFunc("/setTimeout", func(w http.ResponseWriter, r *http.Request) {
-			ts := r.URL.Query().Get("v")
-			t, err := strconv.Atoi(ts)
-			if err != nil {
-				w.Write([]byte("Err " + err.Error()))
-			} else {
-				if t >= 15 || t <= 1 {
-					w.Write([]byte("bad timeout, 1<v<15"))
-				} else {
-					timeout = &t
-					w.Write([]byte("ok, timeout=" + strconv.Itoa(*timeout)))
-				}
-			}
-		})
+	log.Infoln(" ", admin+"/setTimeout?v=10 (POST)")
+	mux.HandleFunc(admin+"/setTimeout", adminHandler(func(w http.ResponseWriter, r *http.Request) {
+		t, err := strconv.Atoi(r.FormValue("v"))
+		if err != nil {
+			http.Error(w, "Err "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if t >= 15 || t <= 1 {
+			http.Error(w, "bad timeout, 1<v<15", http.StatusBadRequest)
+			return
+		}
+		timeout = &t
+		log.Infoln("timeout set to", t, "by", r.RemoteAddr)
+		w.Write([]byte("ok, timeout=" + strconv.Itoa(*timeout)))
+	}))
+
+	if *enablePprof {
+		log.Infoln(" ", admin+"/debug/pprof/")
+		mux.HandleFunc(admin+"/debug/pprof/", authorizedHandler(func(w http.ResponseWriter, r *http.Request) {
+			// pprof.Index serves the profiles by the path after /debug/pprof/
+			r.URL.Path = strings.TrimPrefix(r.URL.Path, admin)
+			pprof.Index(w, r)
+		}))
+		mux.HandleFunc(admin+"/debug/pprof/cmdline", authorizedHandler(pprof.Cmdline))
+		mux.HandleFunc(admin+"/debug/pprof/profile", authorizedHandler(pprof.Profile))
+		mux.HandleFunc(admin+"/debug/pprof/symbol", authorizedHandler(pprof.Symbol))
+		mux.HandleFunc(admin+"/debug/pprof/trace", authorizedHandler(pprof.Trace))
+	}
 
-		log.Infoln("Listening on", *listenAddress)
-		log.Fatal(http.ListenAndServe(*listenAddress, nil))
+	log.Infoln("Listening on", *listenAddress)
+	server := &http.Server{Addr: *listenAddress, Handler: mux}
+	go shutdownOnSignal(server, stopped)
+	if err := listen(server); err != http.ErrServerClosed {
+		log.Fatal(err)
 	}
+	<-stopped
 }
 
 func processOpenFiles() {
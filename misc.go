@@ -2,48 +2,187 @@ package main
 
 import (
 	"database/sql"
+	"fmt"
+	"hash/fnv"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	_ "github.com/sijms/go-ora/v2"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
 )
 
+var labelNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// envRe matches ${VAR} only, a bare $ is part of the v$ view names.
+var envRe = regexp.MustCompile(`\$\{([a-zA-Z_][a-zA-Z0-9_]*)\}`)
+
 type Alert struct {
 	File      string   `yaml:"file"`
 	Ignoreora []string `yaml:"ignoreora"`
 }
 
 type Query struct {
-	Sql     string   `yaml:"sql"`
-	Name    string   `yaml:"name"`
-	Metrics []string `yaml:"metrics"`
-	Labels  []string `yaml:"labels"`
-	Help    string   `yaml:"help"`
+	Sql       string    `yaml:"sql"`
+	Name      string    `yaml:"name"`
+	Metrics   []string  `yaml:"metrics"`
+	Derived   []Derived `yaml:"derived,omitempty"`
+	Labels    []string  `yaml:"labels,omitempty"`
+	Help      string    `yaml:"help"`
+	ValueType string    `yaml:"value_type,omitempty"`
+	Rac       bool      `yaml:"rac,omitempty"` // run per instance on gv$ views, see racSQL
+}
+
+// Derived is a metric computed by the exporter from the numeric columns of a query row.
+type Derived struct {
+	Name string `yaml:"name"`
+	Expr string `yaml:"expr"`
 }
 
 type Config struct {
-	Connection string  `yaml:"connection"`
-	Database   string  `yaml:"database"`
-	Instance   string  `yaml:"instance"`
-	Alertlog   []Alert `yaml:"alertlog"`
-	Queries    []Query `yaml:"queries"`
-	db         *sql.DB
-	hostname   string
+	Connection  string            `yaml:"connection"`
+	WalletPath  string            `yaml:"wallet_path,omitempty"`
+	EncPassword string            `yaml:"password_encrypted,omitempty"` // see -encrypt-password
+	VaultPath   string            `yaml:"vault_path,omitempty"`
+	AwsSecret   string            `yaml:"aws_secret,omitempty"`
+	GcpSecret   string            `yaml:"gcp_secret,omitempty"`
+	CyberArk    *CyberArk         `yaml:"cyberark,omitempty"`
+	Role        string            `yaml:"role,omitempty"`
+	Collectors  *Collectors       `yaml:"collectors,omitempty"`
+	Heavy       *Heavy            `yaml:"heavy,omitempty"`
+	Watch       []Watch           `yaml:"watch,omitempty"`
+	Oem         *Oem              `yaml:"oem,omitempty"`
+	Aq          *Aq               `yaml:"aq,omitempty"`
+	Health      string            `yaml:"health,omitempty"`
+	Namespace   string            `yaml:"namespace,omitempty"`
+	Timeout     int               `yaml:"timeout,omitempty"`
+	Options     map[string]string `yaml:"options,omitempty"`
+	InitSQL     []string          `yaml:"init_sql,omitempty"`
+	Unset       []string          `yaml:"unset,omitempty"`
+	Database    string            `yaml:"database"`
+	Instance    string            `yaml:"instance"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Alertlog    []Alert           `yaml:"alertlog,omitempty"`
+	Queries     []Query           `yaml:"queries,omitempty"`
+	db          *sql.DB
+	hostname    string
+	version     string
+	password    string // decrypted password_encrypted
+	driver      *driverInfo
+	status      *targetStatus // of the last connect and scrape, for /targets
+	ldap        *Ldap
+	discovered  bool // read from targets_file
+	included    bool // read from an include file
+}
+
+// Collectors restrict the globally enabled collectors for one connection, e.g. no
+// tablespace scan on a read-only standby. An empty include list means all.
+type Collectors struct {
+	Include []string `yaml:"include,omitempty"`
+	Exclude []string `yaml:"exclude,omitempty"`
+}
+
+// collectorEnabled reports whether the collector may run against conf.
+func (conf *Config) collectorEnabled(name string) bool {
+	c := conf.Collectors
+	if c == nil {
+		return true
+	}
+	for _, x := range c.Exclude {
+		if x == name {
+			return false
+		}
+	}
+	if len(c.Include) == 0 {
+		return true
+	}
+	for _, x := range c.Include {
+		if x == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Defaults are inherited by every connection, which can override them or drop them with
+// unset, e.g. unset: [timeout, labels.env].
+type Defaults struct {
+	Timeout    int               `yaml:"timeout,omitempty"`
+	Collectors *Collectors       `yaml:"collectors,omitempty"`
+	Options    map[string]string `yaml:"options,omitempty"`
+	Labels     map[string]string `yaml:"labels,omitempty"`
+}
+
+// unsetRe matches the names of unset.
+var unsetRe = regexp.MustCompile(`^(timeout|collectors|options|labels)(\.[^.]+)?$`)
+
+// inherit sets the defaults d not set by conf and not unset.
+func (d *Defaults) inherit(conf *Config) {
+	unset := make(map[string]bool)
+	for _, name := range conf.Unset {
+		unset[name] = true
+	}
+	if conf.Timeout == 0 && !unset["timeout"] {
+		conf.Timeout = d.Timeout
+	}
+	if conf.Collectors == nil && !unset["collectors"] {
+		conf.Collectors = d.Collectors
+	}
+	conf.Options = inheritMap(d.Options, conf.Options, "options", unset)
+	conf.Labels = inheritMap(d.Labels, conf.Labels, "labels", unset)
+}
+
+// inheritMap returns own with the entries of defaults it does not have, except the unset
+// ones (name for all, name.key for one).
+func inheritMap(defaults, own map[string]string, name string, unset map[string]bool) map[string]string {
+	if len(defaults) == 0 || unset[name] {
+		return own
+	}
+	merged := make(map[string]string, len(defaults)+len(own))
+	for k, v := range defaults {
+		if !unset[name+"."+k] {
+			merged[k] = v
+		}
+	}
+	for k, v := range own {
+		merged[k] = v
+	}
+	return merged
+}
+
+// Watch selects active sessions sampled with their wait events, username and module
+// are LIKE patterns, empty matches all.
+type Watch struct {
+	Username string `yaml:"username,omitempty"`
+	Module   string `yaml:"module,omitempty"`
 }
 
 type Configs struct {
-	Cfgs []Config `yaml:"connections"`
+	Include     patterns    `yaml:"include,omitempty"`
+	Ldap        *Ldap       `yaml:"ldap,omitempty"`
+	Defaults    *Defaults   `yaml:"defaults,omitempty"`
+	TargetsFile string      `yaml:"targets_file,omitempty"`
+	SrvTargets  []SrvTarget `yaml:"srv_targets,omitempty"`
+	Cfgs        []Config    `yaml:"connections"`
 }
 
 var (
 	cfgLok          sync.Mutex
+	constLabels     prometheus.Labels
 	config          Configs
+	fileConfig      Configs // the config file as written, for GET /config
 	pwd             string
 	backConnStepAll = make(chan int, 1)
 	testConnStepAll = make(chan int, 1)
@@ -59,45 +198,457 @@ func cleanName(s string) string {
 	return s
 }
 
+// parseLabels parses "key=value,key=value" into labels. The labels the exporter adds to
+// custom metrics and to its own metrics of a connection are rejected, they would collide.
+func parseLabels(s string) (prometheus.Labels, error) {
+	l := prometheus.Labels{}
+	for _, kv := range strings.Split(s, ",") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+		i := strings.Index(kv, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("%q is not key=value", kv)
+		}
+		name := strings.TrimSpace(kv[:i])
+		if !labelNameRe.MatchString(name) {
+			return nil, fmt.Errorf("invalid label name %q", name)
+		}
+		for _, r := range append(reservedLabels, "ipport", "svname") {
+			if name == r {
+				return nil, fmt.Errorf("label %q collides with the label %s added by the exporter", name, r)
+			}
+		}
+		l[name] = strings.TrimSpace(kv[i+1:])
+	}
+	return l, nil
+}
+
+// shortenLabel cuts value to max bytes ending in ~ and the hash of the whole value, so long
+// SQL texts or object names stay distinct and the same value is shortened the same way in
+// every metric, which keeps them joinable.
+func shortenLabel(value string, max int) string {
+	h := fnv.New32a()
+	h.Write([]byte(value))
+	suffix := fmt.Sprintf("~%08x", h.Sum32())
+	n := max - len(suffix)
+	if n < 0 {
+		n = 0
+	}
+	for n > 0 && !utf8.RuneStart(value[n]) {
+		// do not cut a multibyte character
+		n--
+	}
+	return value[:n] + suffix
+}
+
+// onlyTarget returns the metrics gathered by g of the target conf and those of no target,
+// e.g. the scrapes of the exporter. The gathered families are not changed, they may be
+// shared with other requests.
+func onlyTarget(g prometheus.Gatherer, conf *Config) prometheus.Gatherer {
+	ipport, svname := splitConnStr(conf.Connection)
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		mfs, err := g.Gather()
+		var result []*dto.MetricFamily
+		for _, mf := range mfs {
+			var metrics []*dto.Metric
+			for _, m := range mf.Metric {
+				none := label(m, "database") == "" && label(m, "ipport") == ""
+				if none || ofTarget(m, conf.Database, ipport, svname) && ofInstance(m, conf.Instance) {
+					metrics = append(metrics, m)
+				}
+			}
+			if len(metrics) > 0 {
+				result = append(result, &dto.MetricFamily{Name: mf.Name, Help: mf.Help, Type: mf.Type, Metric: metrics})
+			}
+		}
+		return result, err
+	})
+}
+
+// shortLabelMin is the shortest -label.max-length, the ~ and hash suffix of shortenLabel.
+const shortLabelMin = 9
+
+// validLabelMaxLen checks -label.max-length, which leaves room for the suffix of shortenLabel.
+func validLabelMaxLen(max int) error {
+	if max < 0 || max > 0 && max < shortLabelMin {
+		return fmt.Errorf("%d is neither 0 nor at least %d", max, shortLabelMin)
+	}
+	return nil
+}
+
+// shortenLabels shortens the label values of mfs longer than -label.max-length, but not
+// the database and dbinstance labels, which identify the target of a series.
+func shortenLabels(mfs []*dto.MetricFamily) {
+	if *labelMaxLen <= 0 {
+		return
+	}
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			for _, lp := range m.Label {
+				if name := lp.GetName(); name == "database" || name == "dbinstance" {
+					continue
+				}
+				if len(lp.GetValue()) > *labelMaxLen {
+					lp.Value = proto.String(shortenLabel(lp.GetValue(), *labelMaxLen))
+				}
+			}
+		}
+	}
+}
+
+// targetLabels adds the labels of each connection to the metrics of its target (by the
+// database/dbinstance or ipport/svname labels) gathered by g, then shortens label values
+// longer than -label.max-length, with the metric names of the namespaces.
+func targetLabels(g prometheus.Gatherer) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		mfs, err := g.Gather()
+
+		type target struct {
+			database, instance, ipport, svname string
+			labels                             map[string]string
+		}
+		var targets []target
+		cfgLok.Lock()
+		for _, conf := range config.Cfgs {
+			if len(conf.Labels) > 0 {
+				ipport, svname := splitConnStr(conf.Connection)
+				targets = append(targets, target{conf.Database, conf.Instance, ipport, svname, conf.Labels})
+			}
+		}
+		cfgLok.Unlock()
+		if len(targets) == 0 {
+			shortenLabels(mfs)
+			return renameNamespaces(mfs), err
+		}
+
+		for _, mf := range mfs {
+			for _, m := range mf.Metric {
+				for _, t := range targets {
+					if !ofTarget(m, t.database, t.ipport, t.svname) || !ofInstance(m, t.instance) {
+						continue
+					}
+					have := make(map[string]bool)
+					for _, lp := range m.Label {
+						have[lp.GetName()] = true
+					}
+					for name, value := range t.labels {
+						if !have[name] && labelNameRe.MatchString(name) {
+							m.Label = append(m.Label, &dto.LabelPair{Name: proto.String(name), Value: proto.String(value)})
+						}
+					}
+					sort.Slice(m.Label, func(i, j int) bool { return m.Label[i].GetName() < m.Label[j].GetName() })
+					break
+				}
+			}
+		}
+		shortenLabels(mfs)
+		return renameNamespaces(mfs), err
+	})
+}
+
 func cleanIp(s string) string {
 	s = strings.Replace(s, ":", "", -1)  // Remove spaces
 	s = strings.Replace(s, ".", "_", -1) // Remove open parenthesis
 	return s
 }
 
-func loadConfig() bool {
+// expandEnv replaces the ${VAR} placeholders in s by the environment variables.
+func expandEnv(s string) string {
+	return envRe.ReplaceAllStringFunc(s, func(m string) string {
+		name := envRe.FindStringSubmatch(m)[1]
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			log.Warnln("config: environment variable", name, "is not set")
+		}
+		return v
+	})
+}
+
+// applyDefaults passes the defaults of c to its connections.
+func applyDefaults(c *Configs) {
+	if c.Defaults == nil {
+		return
+	}
+	for i := range c.Cfgs {
+		c.Defaults.inherit(&c.Cfgs[i])
+	}
+}
+
+// expandConfig expands the environment variables in the connection settings of c, so
+// secrets can be injected by the environment instead of written to the file, and applies
+// the defaults.
+func expandConfig(c *Configs) {
+	if c.Ldap != nil {
+		c.Ldap.Server = expandEnv(c.Ldap.Server)
+		c.Ldap.Base = expandEnv(c.Ldap.Base)
+		c.Ldap.BindDN = expandEnv(c.Ldap.BindDN)
+		c.Ldap.BindPassword = expandEnv(c.Ldap.BindPassword)
+	}
+	applyDefaults(c)
+	for i := range c.Cfgs {
+		conf := &c.Cfgs[i]
+		conf.ldap = c.Ldap
+		conf.Connection = expandEnv(conf.Connection)
+		conf.WalletPath = expandEnv(conf.WalletPath)
+		conf.VaultPath = expandEnv(conf.VaultPath)
+		conf.AwsSecret = expandEnv(conf.AwsSecret)
+		conf.GcpSecret = expandEnv(conf.GcpSecret)
+		if conf.CyberArk != nil {
+			conf.CyberArk.URL = expandEnv(conf.CyberArk.URL)
+			conf.CyberArk.Cert = expandEnv(conf.CyberArk.Cert)
+			conf.CyberArk.Key = expandEnv(conf.CyberArk.Key)
+			conf.CyberArk.CA = expandEnv(conf.CyberArk.CA)
+		}
+		conf.Database = expandEnv(conf.Database)
+		conf.Instance = expandEnv(conf.Instance)
+		for j := range conf.Alertlog {
+			conf.Alertlog[j].File = expandEnv(conf.Alertlog[j].File)
+		}
+	}
+}
+
+// patterns are file patterns, given as one string or a list.
+type patterns []string
+
+func (p *patterns) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var one string
+	if err := unmarshal(&one); err == nil {
+		*p = patterns{one}
+		return nil
+	}
+	var list []string
+	err := unmarshal(&list)
+	*p = list
+	return err
+}
+
+// includeConfigs merges the connections and srv_targets of the files matched by the include
+// patterns of c into c, relative patterns are relative to dir, the directory of the config
+// file. So connections and their custom queries can be managed by teams in separate files.
+func includeConfigs(c *Configs, dir string) error {
+	for _, pattern := range c.Include {
+		pattern = expandEnv(pattern)
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("include %s: %v", pattern, err)
+		}
+		for _, file := range files {
+			content, err := ioutil.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			var inc Configs
+			if err := yaml.UnmarshalStrict(content, &inc); err != nil {
+				return fmt.Errorf("%s: %v", file, err)
+			}
+			if inc.Ldap != nil || inc.Defaults != nil || inc.TargetsFile != "" || len(inc.Include) > 0 {
+				return fmt.Errorf("%s: only connections and srv_targets can be included", file)
+			}
+			for i := range inc.Cfgs {
+				inc.Cfgs[i].included = true
+			}
+			for i := range inc.SrvTargets {
+				inc.SrvTargets[i].included = true
+			}
+			c.Cfgs = append(c.Cfgs, inc.Cfgs...)
+			c.SrvTargets = append(c.SrvTargets, inc.SrvTargets...)
+		}
+	}
+	return nil
+}
+
+// parseConfig reads the config content with the files of its include patterns, relative
+// to dir, expands the environment variables and defaults and decrypts the passwords.
+func parseConfig(content []byte, dir string) (Configs, error) {
+	var c Configs
+	if err := yaml.Unmarshal(content, &c); err != nil {
+		return c, err
+	}
+	if err := includeConfigs(&c, dir); err != nil {
+		return c, err
+	}
+	expandConfig(&c)
+	if err := decryptPasswords(c.Cfgs); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// loadConfig reads the config file and replaces the running config by it. The running
+// config is kept if the file or one of its include files is broken.
+func loadConfig() error {
 	path, err := filepath.Abs(filepath.Dir(os.Args[0]))
 	if err != nil {
-		log.Fatalf("error: %v", err)
+		return err
 	}
 	pwd = path
 	content, err := ioutil.ReadFile(*configFile)
 	if err != nil {
-		log.Fatalf("error: %v", err)
-		return false
-	} else {
-		var c Configs
-		err := yaml.Unmarshal(content, &c)
+		return err
+	}
+	c, err := parseConfig(content, filepath.Dir(*configFile))
+	if err != nil {
+		return fmt.Errorf("%s: %v", *configFile, err)
+	}
+	var file Configs
+	yaml.Unmarshal(content, &file)
+	var targets []Config
+	sig := ""
+	if c.TargetsFile != "" {
+		c.TargetsFile = expandEnv(c.TargetsFile)
+		if targets, sig, err = readTargets(c.TargetsFile, c.Ldap, c.Defaults); err != nil {
+			// watchTargets reads them again
+			log.Errorln("targets_file:", err)
+		}
+	}
+	for i := range c.SrvTargets {
+		c.SrvTargets[i].Name = expandEnv(c.SrvTargets[i].Name)
+	}
+	resolved := resolveSrv(c.SrvTargets, c.Ldap, c.Defaults, nil)
+	cfgLok.Lock()
+	oldconfig := config
+	go CloseConnection(oldconfig)
+	config, fileConfig = c, file
+	config.Cfgs = append(config.Cfgs, discoveredTargets(targets, c.SrvTargets, resolved)...)
+	targetsSig, fileTargets, srvCache = sig, targets, resolved
+	configSeq++
+	cfgLok.Unlock()
+	return nil
+}
+
+// dsn returns the go-ora connection URL of conf with the authentication options added.
+func (conf *Config) dsn() (string, error) {
+	conn := conf.Connection
+	if !strings.Contains(conn, "://") {
+		// user/password@alias of tnsnames.ora or easy connect
+		var err error
+		if conn, err = tnsURL(conn, conf.ldap); err != nil {
+			return "", err
+		}
+	}
+	p, _ := conf.provider()
+	if conf.WalletPath == "" && p == nil && conf.Role == "" && conf.password == "" && len(conf.Options) == 0 {
+		return conn, nil
+	}
+	u, err := url.Parse(conn)
+	if err != nil {
+		return "", fmt.Errorf("connection is not an URL: %v", err)
+	}
+	if conf.WalletPath != "" {
+		q := u.Query()
+		// user and password are read from the wallet entry of host:port/service
+		q.Set("WALLET", conf.WalletPath)
+		u.RawQuery = q.Encode()
+	}
+	if len(conf.Options) > 0 {
+		// go-ora URL options like PREFETCH_ROWS or SSL
+		q := u.Query()
+		for k, v := range conf.Options {
+			q.Set(k, v)
+		}
+		u.RawQuery = q.Encode()
+	}
+	if conf.Role != "" {
+		// sysdba or sysoper, e.g. for mounted standbys and ASM instances
+		q := u.Query()
+		q.Set("DBA PRIVILEGE", conf.Role)
+		u.RawQuery = q.Encode()
+	}
+	if p != nil {
+		s, err := credentials(conf)
 		if err != nil {
-			log.Fatalf("error: %v", err)
-			return false
+			return "", err
 		}
-		cfgLok.Lock()
-		oldconfig := config
-		go CloseConnection(oldconfig)
-		config = c
-		cfgLok.Unlock()
-		return true
+		user := s.user
+		if user == "" {
+			user = u.User.Username()
+		}
+		u.User = url.UserPassword(user, s.password)
+	} else if conf.password != "" {
+		u.User = url.UserPassword(u.User.Username(), conf.password)
+	}
+	return u.String(), nil
+}
+
+// major returns the major release of the connected database, 0 if unknown.
+func (conf *Config) major() int {
+	n, _ := strconv.Atoi(strings.SplitN(conf.version, ".", 2)[0])
+	return n
+}
+
+// scrapeTimeout returns the scrape timeout of conf, the timeout field or else -timeout.
+func (conf *Config) scrapeTimeout() time.Duration {
+	if conf.Timeout > 0 {
+		return time.Duration(conf.Timeout) * time.Second
 	}
+	return time.Duration(*timeout) * time.Second
 }
 
-func WriteLog(message string) {
-	fh, err := os.OpenFile(pwd+"/"+*logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err == nil {
-		fh.Seek(0, 2)
-		fh.WriteString(time.Now().Format("2006-01-02 15:04:05") + " " + message + "\n")
+// findTarget returns the configured connection with the given database or instance name.
+func findTarget(name string) *Config {
+	cfgLok.Lock()
+	defer cfgLok.Unlock()
+	for i := range config.Cfgs {
+		if config.Cfgs[i].Database == name || config.Cfgs[i].Instance == name {
+			return &config.Cfgs[i]
+		}
+	}
+	return nil
+}
+
+// copyTarget returns a copy of the configured connection with the given database or
+// instance name, to work on without holding cfgLok.
+func copyTarget(name string) (Config, bool) {
+	cfgLok.Lock()
+	defer cfgLok.Unlock()
+	for i := range config.Cfgs {
+		if config.Cfgs[i].Database == name || config.Cfgs[i].Instance == name {
+			return config.Cfgs[i], true
+		}
 	}
-	if fh != nil {
-		fh.Close()
+	return Config{}, false
+}
+
+// counterVec holds the totals of a counter read from the database, like the cumulative
+// columns of v$enqueue_stat, which are exported as counters with the values as read.
+type counterVec struct {
+	desc   *prometheus.Desc
+	lok    sync.Mutex
+	values map[string]counterValue // by the joined label values
+}
+
+type counterValue struct {
+	value  float64
+	labels []string
+}
+
+func newCounterVec(subsystem, name, help string, labels ...string) *counterVec {
+	fqName := prometheus.BuildFQName(namespace, subsystem, name)
+	markDBCounter(fqName)
+	return &counterVec{
+		desc:   prometheus.NewDesc(fqName, help, labels, nil),
+		values: make(map[string]counterValue),
+	}
+}
+
+// Set sets the total of the counter with the label values.
+func (v *counterVec) Set(value float64, labels ...string) {
+	v.lok.Lock()
+	defer v.lok.Unlock()
+	v.values[strings.Join(labels, "\xff")] = counterValue{value, labels}
+}
+
+func (v *counterVec) Describe(ch chan<- *prometheus.Desc) { ch <- v.desc }
+
+func (v *counterVec) Collect(ch chan<- prometheus.Metric) {
+	v.lok.Lock()
+	defer v.lok.Unlock()
+	for _, c := range v.values {
+		ch <- prometheus.MustNewConstMetric(v.desc, prometheus.CounterValue, c.value, c.labels...)
 	}
 }
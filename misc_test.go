@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestShortenLabel(t *testing.T) {
+	long := strings.Repeat("select * from dual ", 10)
+	tests := []struct {
+		value   string
+		max     int
+		wantLen int
+		prefix  string
+	}{
+		{long, 20, 20, "select * fr"},
+		{long, 9, 9, ""},
+		{long, 64, 64, long[:55]},
+		{"äöüäöüäöüäöü", 14, 13, "äö"},
+	}
+	for _, tt := range tests {
+		got := shortenLabel(tt.value, tt.max)
+		if len(got) != tt.wantLen || len(got) > tt.max {
+			t.Errorf("shortenLabel(%q, %d) = %q, %d bytes, want %d", tt.value, tt.max, got, len(got), tt.wantLen)
+		}
+		if !strings.HasPrefix(got, tt.prefix) || !utf8.ValidString(got) {
+			t.Errorf("shortenLabel(%q, %d) = %q, want a valid string beginning with %q", tt.value, tt.max, got, tt.prefix)
+		}
+		if got != shortenLabel(tt.value, tt.max) {
+			t.Errorf("shortenLabel(%q, %d) is not stable", tt.value, tt.max)
+		}
+	}
+	if shortenLabel(long+"a", 20) == shortenLabel(long+"b", 20) {
+		t.Error("values with the same prefix are shortened to the same value")
+	}
+}
+
+func TestValidLabelMaxLen(t *testing.T) {
+	tests := []struct {
+		max     int
+		wantErr bool
+	}{
+		{0, false},
+		{-1, true},
+		{1, true},
+		{8, true},
+		{9, false},
+		{128, false},
+	}
+	for _, tt := range tests {
+		if err := validLabelMaxLen(tt.max); (err != nil) != tt.wantErr {
+			t.Errorf("validLabelMaxLen(%d) = %v, want error %v", tt.max, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"", map[string]string{}, false},
+		{"region=eu1, dc = fra", map[string]string{"region": "eu1", "dc": "fra"}, false},
+		{"region", nil, true},
+		{"1region=eu1", nil, true},
+		{"database=prod", nil, true},
+		{"dbinstance=prod1", nil, true},
+		{"svname=APP", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseLabels(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseLabels(%q) error %v, want error %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("parseLabels(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+		for name, value := range tt.want {
+			if got[name] != value {
+				t.Errorf("parseLabels(%q)[%s] = %q, want %q", tt.s, name, got[name], value)
+			}
+		}
+	}
+}
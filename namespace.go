@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// namespaceRe matches the valid metric name prefixes.
+var namespaceRe = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// validNamespace checks a namespace of -namespace or of a connection.
+func validNamespace(ns string) error {
+	if !namespaceRe.MatchString(ns) {
+		return fmt.Errorf("invalid namespace %q", ns)
+	}
+	return nil
+}
+
+// renameNamespaces replaces the oracledb prefix of the metric names by -namespace, or by
+// the namespace of the connection for the series of its target, e.g. oracle_ for
+// dashboards of a legacy exporter.
+func renameNamespaces(mfs []*dto.MetricFamily) []*dto.MetricFamily {
+	type target struct {
+		database, instance, ipport, svname string
+		namespace                          string
+	}
+	var targets []target
+	cfgLok.Lock()
+	for _, conf := range config.Cfgs {
+		if conf.Namespace != "" && conf.Namespace != *namespaceFlag {
+			ipport, svname := splitConnStr(conf.Connection)
+			targets = append(targets, target{conf.Database, conf.Instance, ipport, svname, conf.Namespace})
+		}
+	}
+	cfgLok.Unlock()
+	if len(targets) == 0 && *namespaceFlag == namespace {
+		return mfs
+	}
+
+	byName := make(map[string]*dto.MetricFamily)
+	var result []*dto.MetricFamily
+	family := func(mf *dto.MetricFamily, ns string) *dto.MetricFamily {
+		name := ns + strings.TrimPrefix(mf.GetName(), namespace)
+		if f, ok := byName[name]; ok {
+			return f
+		}
+		f := &dto.MetricFamily{Name: proto.String(name), Help: mf.Help, Type: mf.Type}
+		if isDBCounter(mf.GetName()) {
+			// keeps it without _created series under its new name
+			markDBCounter(name)
+		}
+		byName[name] = f
+		result = append(result, f)
+		return f
+	}
+	for _, mf := range mfs {
+		if !strings.HasPrefix(mf.GetName(), namespace+"_") {
+			result = append(result, mf)
+			continue
+		}
+		for _, m := range mf.Metric {
+			ns := *namespaceFlag
+			for _, t := range targets {
+				if ofTarget(m, t.database, t.ipport, t.svname) && ofInstance(m, t.instance) {
+					ns = t.namespace
+					break
+				}
+			}
+			f := family(mf, ns)
+			f.Metric = append(f.Metric, m)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].GetName() < result[j].GetName() })
+	return result
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Oem marks a connection to the repository of Oracle Enterprise Manager, one connection
+// yields the availability of every registered target instead of a connection per database.
+type Oem struct {
+	// TargetTypes are the OEM target types exported, by default the databases
+	TargetTypes []string    `yaml:"target_types,omitempty"`
+	Metrics     []OemMetric `yaml:"metrics,omitempty"`
+}
+
+// OemMetric selects a metric column collected by OEM, e.g. metric tbspAllocation and
+// column spaceUsedPercent.
+type OemMetric struct {
+	Metric string `yaml:"metric"`
+	Column string `yaml:"column"`
+}
+
+// oemTargetTypes are exported if target_types is not set.
+var oemTargetTypes = []string{"oracle_database", "rac_database", "oracle_pdb"}
+
+// oemViews are the repository views read by ScrapeOem.
+var oemViews = []string{"sysman.mgmt$target", "sysman.mgmt$availability_current", "sysman.mgmt$metric_current"}
+
+// inList returns the placeholders :first... of n bind values for an IN list.
+func inList(first, n int) string {
+	binds := make([]string, n)
+	for i := range binds {
+		binds[i] = fmt.Sprintf(":%d", first+i)
+	}
+	return strings.Join(binds, ", ")
+}
+
+// ScrapeOem collects the availability and the selected metrics of the targets in the OEM
+// repository conn is connected to.
+func (e *Exporter) ScrapeOem(ctx context.Context, conn *Config) error {
+	if conn.db == nil || conn.Oem == nil {
+		return nil
+	}
+	types := conn.Oem.TargetTypes
+	if len(types) == 0 {
+		types = oemTargetTypes
+	}
+	args := make([]interface{}, len(types))
+	for i, t := range types {
+		args[i] = t
+	}
+
+	rows, err := conn.db.QueryContext(ctx, `select t.target_name, t.target_type, nvl(t.host_name, ' '), a.availability_status
+                                  from sysman.mgmt$target t
+                                  join sysman.mgmt$availability_current a on a.target_guid = t.target_guid
+                                  where t.target_type in (`+inList(1, len(types))+`)`, args...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var name, typ, host, status string
+		if err = rows.Scan(&name, &typ, &host, &status); err != nil {
+			break
+		}
+		up := 0.0
+		if status == "Target Up" {
+			up = 1
+		}
+		e.oemUp.WithLabelValues(conn.Database, conn.Instance, name, typ, strings.TrimSpace(host), status).Set(up)
+	}
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range conn.Oem.Metrics {
+		rows, err := conn.db.QueryContext(ctx, `select target_name, target_type, nvl(key_value, ' '), value
+                                      from sysman.mgmt$metric_current
+                                      where metric_name = :1 and metric_column = :2 and value is not null
+                                      and target_type in (`+inList(3, len(types))+`)`,
+			append([]interface{}{m.Metric, m.Column}, args...)...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var name, typ, key string
+			var value float64
+			if err = rows.Scan(&name, &typ, &key, &value); err != nil {
+				break
+			}
+			e.oemMetric.WithLabelValues(conn.Database, conn.Instance, name, typ, m.Metric, m.Column, strings.TrimSpace(key)).Set(value)
+		}
+		rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
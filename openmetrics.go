@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// createdAt are the times the counters of the exporter were first gathered, exported as
+// their _created series in the OpenMetrics format. Series not gathered for createdKeep are
+// forgotten, they start anew if they come back. The counters read from the database, see
+// counterVec, count since the start of the instance and have no _created series.
+var (
+	createdLok  sync.Mutex
+	createdAt   = make(map[string]time.Time)
+	createdSeen = make(map[string]time.Time)
+	dbCounters  = make(map[string]bool) // names of the counterVec families, also renamed
+)
+
+// markDBCounter records name as the name of a family of counters read from the database.
+func markDBCounter(name string) {
+	createdLok.Lock()
+	dbCounters[name] = true
+	createdLok.Unlock()
+}
+
+// isDBCounter reports whether name is a family of counters read from the database.
+func isDBCounter(name string) bool {
+	createdLok.Lock()
+	defer createdLok.Unlock()
+	return dbCounters[name]
+}
+
+const createdKeep = time.Hour
+
+// seriesKey identifies a series of mf by its name and labels.
+func seriesKey(mf *dto.MetricFamily, m *dto.Metric) string {
+	pairs := make([]string, 0, len(m.Label))
+	for _, l := range m.Label {
+		pairs = append(pairs, l.GetName()+"="+l.GetValue())
+	}
+	sort.Strings(pairs)
+	return mf.GetName() + "{" + strings.Join(pairs, ",") + "}"
+}
+
+// counterCreated returns the creation times of the counter series of mfs.
+func counterCreated(mfs []*dto.MetricFamily) map[string]time.Time {
+	now := time.Now()
+	created := make(map[string]time.Time)
+	createdLok.Lock()
+	defer createdLok.Unlock()
+	for _, mf := range mfs {
+		if mf.GetType() != dto.MetricType_COUNTER || dbCounters[mf.GetName()] {
+			continue
+		}
+		for _, m := range mf.Metric {
+			key := seriesKey(mf, m)
+			t, ok := createdAt[key]
+			if !ok {
+				t = now
+				createdAt[key] = t
+			}
+			createdSeen[key] = now
+			created[key] = t
+		}
+	}
+	for key, seen := range createdSeen {
+		if now.Sub(seen) > createdKeep {
+			delete(createdAt, key)
+			delete(createdSeen, key)
+		}
+	}
+	return created
+}
+
+// writeOpenMetrics writes mfs in the OpenMetrics format, every sample of a counter of the
+// exporter followed by its _created sample, which expfmt does not write.
+func writeOpenMetrics(w io.Writer, mfs []*dto.MetricFamily) error {
+	created := counterCreated(mfs)
+	var buf bytes.Buffer
+	// single writes without their # TYPE line, there is no HELP without Help
+	sample := func(mf *dto.MetricFamily) error {
+		buf.Reset()
+		if _, err := expfmt.MetricFamilyToOpenMetrics(&buf, mf); err != nil {
+			return err
+		}
+		line := buf.Bytes()
+		if i := bytes.IndexByte(line, '\n'); i >= 0 {
+			line = line[i+1:]
+		}
+		_, err := w.Write(line)
+		return err
+	}
+	for _, mf := range mfs {
+		if mf.GetType() != dto.MetricType_COUNTER || !strings.HasSuffix(mf.GetName(), "_total") || isDBCounter(mf.GetName()) {
+			if _, err := expfmt.MetricFamilyToOpenMetrics(w, mf); err != nil {
+				return err
+			}
+			continue
+		}
+		// the HELP and TYPE lines of the family
+		if _, err := expfmt.MetricFamilyToOpenMetrics(w, &dto.MetricFamily{Name: mf.Name, Help: mf.Help, Type: mf.Type}); err != nil {
+			return err
+		}
+		createdName := proto.String(strings.TrimSuffix(mf.GetName(), "_total") + "_created")
+		for _, m := range mf.Metric {
+			if err := sample(&dto.MetricFamily{Name: mf.Name, Type: mf.Type, Metric: []*dto.Metric{m}}); err != nil {
+				return err
+			}
+			t := created[seriesKey(mf, m)]
+			c := &dto.Metric{Label: m.Label, Gauge: &dto.Gauge{Value: proto.Float64(float64(t.UnixNano()) / 1e9)}}
+			if err := sample(&dto.MetricFamily{Name: createdName, Type: dto.MetricType_GAUGE.Enum(), Metric: []*dto.Metric{c}}); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := expfmt.FinalizeOpenMetrics(w)
+	return err
+}
+
+// metricsHandler serves g in the format negotiated with the client: the OpenMetrics format
+// with _created series for the counters of the exporter if the client accepts it, else the
+// text or protobuf format of promhttp.
+func metricsHandler(g prometheus.Gatherer) http.Handler {
+	classic := promhttp.HandlerFor(g, promhttp.HandlerOpts{EnableOpenMetrics: true})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format := expfmt.NegotiateIncludingOpenMetrics(r.Header)
+		if format != expfmt.FmtOpenMetrics {
+			classic.ServeHTTP(w, r)
+			return
+		}
+		mfs, err := g.Gather()
+		if err != nil {
+			http.Error(w, "An error has occurred while serving metrics:\n\n"+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", string(format))
+		out := io.Writer(w)
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			out = gz
+		}
+		writeOpenMetrics(out, mfs)
+	})
+}
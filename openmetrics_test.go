@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestWriteOpenMetricsCreated(t *testing.T) {
+	defer func(ns string) { *namespaceFlag = ns }(*namespaceFlag)
+	enq := newCounterVec("enqueue", "test_requests_total", "Requests.", "enqueue_type")
+	enq.Set(5, "TX")
+	scrapes := prometheus.NewCounter(prometheus.CounterOpts{Namespace: namespace, Name: "test_scrapes_total", Help: "Scrapes."})
+	scrapes.Inc()
+
+	tests := []struct {
+		namespace string
+		created   string
+		none      string
+	}{
+		{namespace, "oracledb_test_scrapes_created", "oracledb_enqueue_test_requests_created"},
+		{"oracle", "oracle_test_scrapes_created", "oracle_enqueue_test_requests_created"},
+	}
+	for _, tt := range tests {
+		*namespaceFlag = tt.namespace
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(enq, scrapes)
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := writeOpenMetrics(&buf, renameNamespaces(mfs)); err != nil {
+			t.Fatal(err)
+		}
+		out := buf.String()
+		if !strings.Contains(out, tt.created) {
+			t.Errorf("namespace %s: no %s in\n%s", tt.namespace, tt.created, out)
+		}
+		if strings.Contains(out, tt.none) {
+			t.Errorf("namespace %s: counter read from the database has %s", tt.namespace, tt.none)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// scrapeOptions are the parameters of one scrape. They are parsed once from the request
+// and not changed afterwards, so concurrent scrapes with different parameters do not
+// affect each other.
+type scrapeOptions struct {
+	defaultMetrics bool
+	recovery       bool
+	tableRows      bool
+	tableBytes     bool
+	indexBytes     bool
+	lobBytes       bool
+	objectChanges  bool
+	userStats      bool
+	security       bool
+	optional       map[string]bool // optional collectors enabled, see optionalCollectors
+	collect        map[string]bool // collect[] of the request, all collectors if empty
+	debug          bool            // debug metrics for this response only, header X-Debug-Scrape: 1
+	heavy          bool            // a run of the heavy tiers, see Heavy
+	remoteIP       string          // of the scraping client, empty without request
+	timeout        time.Duration   // by X-Prometheus-Scrape-Timeout-Seconds, -timeout if 0
+	target         string          // database or instance of the only connection scraped, all if empty
+}
+
+// defaultCollectors are the collectors enabled by -defaultmetrics.
+var defaultCollectors = []string{"uptime", "session", "sysstat", "waitclass", "sysmetric", "aas", "tablespace",
+	"datafiles", "interconnect", "redo", "cache", "alertlog", "services", "parameter",
+	"components", "directories", "patch", "asmspace", "locations", "tempundo", "cursors", "clockskew"}
+
+// optionalCollectors run only if enabled by -collectors.optional, collect[] or a heavy tier,
+// so an upgrade does not add their queries to the scrapes of every database. dbsize sums
+// dba_segments and belongs into a heavy tier on large databases, parameterchanges exports
+// a series per parameter and target.
+var optionalCollectors = []string{"parameterchanges", "pdbs", "jobs", "dbsize", "awr", "memory", "dataguard", "dgbroker",
+	"archivedest", "blocking", "enqueue", "longops", "undo", "temp", "resource"}
+
+// optionalEnabled are the optional collectors of -collectors.optional, set at the start.
+var optionalEnabled map[string]bool
+
+// parseOptional returns the optional collectors of s, a comma separated list of names.
+func parseOptional(s string) (map[string]bool, error) {
+	enabled := make(map[string]bool)
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		known := false
+		for _, c := range optionalCollectors {
+			known = known || c == name
+		}
+		if !known {
+			return nil, fmt.Errorf("%q is not one of the optional collectors %s", name, strings.Join(optionalCollectors, ", "))
+		}
+		enabled[name] = true
+	}
+	return enabled, nil
+}
+
+// flagOptions returns the options of scrapes without request parameters, set by the flags.
+func flagOptions() *scrapeOptions {
+	return &scrapeOptions{
+		defaultMetrics: *pMetrics,
+		recovery:       *pRecovery,
+		tableRows:      *pTabRows,
+		tableBytes:     *pTabBytes,
+		indexBytes:     *pIndBytes,
+		lobBytes:       *pLobBytes,
+		objectChanges:  *pObjChange,
+		userStats:      *pUserStats,
+		security:       *pSecurity,
+		optional:       optionalEnabled,
+	}
+}
+
+// requestOptions returns the options of a scrape by r: the flags, plus the collectors
+// enabled by URL parameters like tablerows=true, the target of /metrics/<database> or
+// target=, plus the debug header and the scrape timeout of Prometheus. collect[] (as
+// of the node_exporter) runs only the listed collectors, whatever the flags enable.
+func requestOptions(r *http.Request) (*scrapeOptions, error) {
+	o := flagOptions()
+	q := r.URL.Query()
+	if names := q["collect[]"]; len(names) > 0 {
+		if err := o.restrict(names); err != nil {
+			return nil, fmt.Errorf("collect[]: %v", err)
+		}
+	}
+	o.recovery = o.recovery || q.Get("recovery") == "true"
+	o.tableRows = o.tableRows || q.Get("tablerows") == "true"
+	o.tableBytes = o.tableBytes || q.Get("tablebytes") == "true"
+	o.indexBytes = o.indexBytes || q.Get("indexbytes") == "true"
+	o.lobBytes = o.lobBytes || q.Get("lobbytes") == "true"
+	o.objectChanges = o.objectChanges || q.Get("objectchanges") == "true"
+	o.target = q.Get("target")
+	if name := strings.TrimPrefix(r.URL.Path, strings.TrimRight(*metricPath, "/")+"/"); name != r.URL.Path {
+		// /metrics/<database>
+		o.target = name
+	}
+	o.debug = r.Header.Get("X-Debug-Scrape") == "1"
+	if v := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"); v != "" {
+		if secs, err := strconv.ParseFloat(v, 64); err == nil && secs > 0 {
+			o.timeout = time.Duration(secs * float64(time.Second))
+			if o.timeout > *timeoutOffset {
+				o.timeout -= *timeoutOffset
+			}
+		}
+	}
+	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		o.remoteIP = ip
+	}
+	return o, nil
+}
+
+// restrict limits o to the collectors names, as collect[] of a request.
+func (o *scrapeOptions) restrict(names []string) error {
+	o.collect = make(map[string]bool)
+	for _, name := range names {
+		if !knownCollector(name) {
+			return fmt.Errorf("unknown collector %q", name)
+		}
+		o.collect[name] = true
+	}
+	o.defaultMetrics = false
+	for _, name := range defaultCollectors {
+		o.defaultMetrics = o.defaultMetrics || o.collect[name]
+	}
+	o.recovery = o.collect["recovery"]
+	o.tableRows = o.collect["tablerows"]
+	o.tableBytes = o.collect["tablebytes"]
+	o.indexBytes = o.collect["indexbytes"]
+	o.lobBytes = o.collect["lobbytes"]
+	o.objectChanges = o.collect["objectchanges"]
+	o.userStats = o.collect["userstats"]
+	o.security = o.collect["security"]
+	o.optional = make(map[string]bool)
+	for _, name := range optionalCollectors {
+		o.optional[name] = o.collect[name]
+	}
+	return nil
+}
+
+// runs reports whether the collector name of collectorNames is enabled by o, before the
+// collectors and heavy tiers of the connections.
+func (o *scrapeOptions) runs(name string) bool {
+	if !o.collects(name) {
+		return false
+	}
+	switch name {
+	case "recovery":
+		return o.recovery
+	case "tablerows":
+		return o.tableRows
+	case "tablebytes":
+		return o.tableBytes
+	case "indexbytes":
+		return o.indexBytes
+	case "lobbytes":
+		return o.lobBytes
+	case "objectchanges":
+		return o.objectChanges
+	case "userstats":
+		return o.userStats
+	case "security":
+		return o.security
+	}
+	for _, c := range defaultCollectors {
+		if c == name {
+			return o.defaultMetrics
+		}
+	}
+	for _, c := range optionalCollectors {
+		if c == name {
+			return o.optional[name]
+		}
+	}
+	// custom, watch, oem and aq run for the connections configuring them
+	return true
+}
+
+// collects reports whether the collector runs in this scrape by collect[].
+func (o *scrapeOptions) collects(name string) bool {
+	return len(o.collect) == 0 || o.collect[name]
+}
+
+// withOptions returns a collector of e scraping with opts, registered per request
+// because prometheus.Collector has no way to pass parameters to Collect.
+func (e *Exporter) withOptions(opts *scrapeOptions) prometheus.Collector {
+	return collectorFunc(func(ch chan<- prometheus.Metric) { e.collect(ch, opts) })
+}
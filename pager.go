@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// pagerRow is one row of a paged dictionary scan.
+type pagerRow struct {
+	owner, name string
+	extra       []string
+	value       float64
+}
+
+// keysetPager fetches a large dictionary scan page by page ordered by (owner, name).
+// When the scrape deadline is reached it stops after the current page and the next
+// scrape resumes from the last key; meanwhile the rows of the previous pass are kept,
+// so a scan which needs several scrapes still exports every table.
+type keysetPager struct {
+	mu          sync.Mutex
+	owner, name string
+	rows        map[[2]string]pagerRow
+	seen        map[[2]string]bool
+}
+
+func (e *Exporter) pager(collector string, conn *Config) *keysetPager {
+	e.pagerLok.Lock()
+	defer e.pagerLok.Unlock()
+	key := collector + "/" + conn.Database + "/" + conn.Instance
+	p, ok := e.pagers[key]
+	if !ok {
+		p = &keysetPager{rows: make(map[[2]string]pagerRow), seen: make(map[[2]string]bool)}
+		e.pagers[key] = p
+	}
+	return p
+}
+
+// scan continues the paged pass over query and returns all known rows and the error which
+// stopped the pass, if any. query has to select the columns owner, name, extra columns and
+// value, (owner, name) must be unique.
+func (p *keysetPager) scan(ctx context.Context, db *sql.DB, query string, extra int) ([]pagerRow, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	paged := `select * from (select * from (` + query + `)
+                                 where :1 is null or owner > :2 or (owner = :3 and name > :4)
+                                 order by owner, name)
+                                 where rownum <= :5`
+	var err error
+	for ctx.Err() == nil {
+		var rows *sql.Rows
+		rows, err = db.QueryContext(ctx, paged, p.owner, p.owner, p.owner, p.name, *pageSize)
+		if err != nil {
+			break
+		}
+		n := 0
+		for rows.Next() {
+			var r pagerRow
+			extras := make([]sql.NullString, extra)
+			dest := []interface{}{&r.owner, &r.name}
+			for i := range extras {
+				dest = append(dest, &extras[i])
+			}
+			if err = rows.Scan(append(dest, &r.value)...); err != nil {
+				break
+			}
+			for _, x := range extras {
+				r.extra = append(r.extra, x.String)
+			}
+			key := [2]string{r.owner, r.name}
+			p.rows[key] = r
+			p.seen[key] = true
+			p.owner, p.name = r.owner, r.name
+			n++
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+		rows.Close()
+		if err != nil {
+			log.Debugln("paged scan stopped at", p.owner, p.name, err)
+			break
+		}
+		if n < *pageSize {
+			// pass complete, forget dropped tables and start over on the next scrape
+			for key := range p.rows {
+				if !p.seen[key] {
+					delete(p.rows, key)
+				}
+			}
+			p.seen = make(map[[2]string]bool)
+			p.owner, p.name = "", ""
+			break
+		}
+	}
+
+	result := make([]pagerRow, 0, len(p.rows))
+	for _, r := range p.rows {
+		result = append(result, r)
+	}
+	return result, err
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// HealthzHandler is the liveness probe, it answers as long as the HTTP server runs.
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok\n"))
+}
+
+// ReadyzHandler is the readiness probe for Kubernetes and load balancers: ready once a
+// target is connected. The HTTP server is started after the config was loaded.
+func ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	cfgLok.Lock()
+	connected := 0
+	for _, conn := range config.Cfgs {
+		if conn.db != nil {
+			connected++
+		}
+	}
+	total := len(config.Cfgs)
+	cfgLok.Unlock()
+
+	if connected == 0 {
+		http.Error(w, fmt.Sprintf("not ready, 0 of %d targets connected", total), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintf(w, "ok, %d of %d targets connected\n", connected, total)
+}
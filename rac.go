@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// vViewRe matches the v$ views of a query, not the gv$ ones.
+var vViewRe = regexp.MustCompile(`(?i)\bv\$(\w+)`)
+
+// racSQL rewrites the v$ views of a rac custom query to their gv$ view restricted to one
+// instance, so the query returns the rows of that instance whatever it does with them:
+// joins, group by and aggregates work as on that instance. Qualify columns with an alias,
+// not with the view name, e.g. s.sid instead of v$session.sid.
+func racSQL(sql, instID string) string {
+	return vViewRe.ReplaceAllString(sql, fmt.Sprintf("(SELECT * FROM gv$$$1 WHERE inst_id = %s)", instID))
+}
+
+// instanceIDs returns the inst_id of the open instances of the database of conn, 1 for a
+// single instance.
+func instanceIDs(ctx context.Context, conn *Config) ([]string, error) {
+	rows, err := conn.db.QueryContext(ctx, `SELECT inst_id FROM gv$instance ORDER BY inst_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []string{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, strconv.Itoa(id))
+	}
+	return ids, rows.Err()
+}
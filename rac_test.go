@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestRacSQL(t *testing.T) {
+	tests := []struct {
+		sql    string
+		instID string
+		want   string
+	}{
+		{"select count(*) from v$session", "1",
+			"select count(*) from (SELECT * FROM gv$session WHERE inst_id = 1)"},
+		{"select s.sid from V$SESSION s, v$process p where s.paddr = p.addr", "2",
+			"select s.sid from (SELECT * FROM gv$SESSION WHERE inst_id = 2) s, (SELECT * FROM gv$process WHERE inst_id = 2) p where s.paddr = p.addr"},
+		{"select inst_id, count(*) from gv$session group by inst_id", "1",
+			"select inst_id, count(*) from gv$session group by inst_id"},
+		{"select count(*) from dba_users", "3", "select count(*) from dba_users"},
+	}
+	for _, tt := range tests {
+		if got := racSQL(tt.sql, tt.instID); got != tt.want {
+			t.Errorf("racSQL(%q, %s) = %q, want %q", tt.sql, tt.instID, got, tt.want)
+		}
+	}
+}
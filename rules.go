@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+// alertRule is a Prometheus alerting rule.
+type alertRule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+type ruleGroup struct {
+	Name  string      `yaml:"name"`
+	Rules []alertRule `yaml:"rules"`
+}
+
+// alertRules returns the alerting rules for the metrics of the collectors enabled by o and
+// the connections of the config, with the thresholds of the -rules.* flags and the metric
+// names of -namespace. A rule is left out if no connection runs its collector.
+func alertRules(o *scrapeOptions) []alertRule {
+	rule := func(name, expr, severity, summary string) alertRule {
+		return alertRule{
+			Alert:       name,
+			Expr:        strings.ReplaceAll(expr, namespace+"_", *namespaceFlag+"_"),
+			For:         model.Duration(*rulesFor).String(),
+			Labels:      map[string]string{"severity": severity},
+			Annotations: map[string]string{"summary": summary},
+		}
+	}
+	rules := []alertRule{
+		rule("OracleDown", `oracledb_up == 0`, "critical",
+			"Oracle {{ $labels.database }}/{{ $labels.dbinstance }} can not be connected"),
+		rule("OracleExporterScrapeError", `oracledb_exporter_last_scrape_error == 1`, "warning",
+			"The last scrape of the Oracle exporter {{ $labels.instance }} failed"),
+	}
+
+	cfgLok.Lock()
+	defer cfgLok.Unlock()
+	// enabled reports whether a connection runs the collector, by the scrapes or its heavy tier
+	enabled := func(collector string) bool {
+		for _, conn := range config.Cfgs {
+			if conn.collectorEnabled(collector) && (o.runs(collector) || conn.Heavy.runs(collector)) {
+				return true
+			}
+		}
+		return false
+	}
+	add := func(collector string, r ...alertRule) {
+		if enabled(collector) {
+			rules = append(rules, r...)
+		}
+	}
+	add("uptime", rule("OracleInstanceRestarted", `increase(oracledb_instance_restarts_total[1h]) > 0`, "warning",
+		"Oracle {{ $labels.database }}/{{ $labels.dbinstance }} was restarted"))
+	add("tablespace", rule("OracleTablespaceFull", fmt.Sprintf(`oracledb_tablespace{type="used"} / ignoring(type) oracledb_tablespace{type="total"} * 100 > %g`, *rulesTsPct), "warning",
+		"Tablespace {{ $labels.name }} of {{ $labels.database }} is {{ $value | humanize }}% full"))
+	add("asmspace",
+		rule("OracleAsmDiskgroupFull", fmt.Sprintf(`oracledb_asmspace{type="used"} / ignoring(type) oracledb_asmspace{type="total"} * 100 > %g`, *rulesTsPct), "warning",
+			"ASM diskgroup {{ $labels.name }} of {{ $labels.database }} is {{ $value | humanize }}% full"),
+		rule("OracleAsmDiskOffline", `oracledb_asm_disk_online == 0`, "critical",
+			"ASM disk {{ $labels.disk }} of diskgroup {{ $labels.diskgroup }} is {{ $labels.mode_status }}"),
+		rule("OracleAsmRedundancyLost", `oracledb_asm_diskgroup_bytes{type="usable_file"} < 0`, "critical",
+			"ASM diskgroup {{ $labels.diskgroup }} of {{ $labels.database }} could not restore its redundancy after a disk failure"))
+	add("jobs",
+		rule("OracleDatapumpJobNotRunning", `oracledb_datapump_jobs{state="NOT RUNNING"} > 0`, "warning",
+			"{{ $value }} stopped or failed Data Pump jobs of {{ $labels.database }} left their master tables"),
+		rule("OracleRmanJobFailed", `oracledb_rman_jobs{status=~"FAILED|.*WITH ERRORS"} > 0`, "warning",
+			"RMAN {{ $labels.operation }} of {{ $labels.database }} ended {{ $labels.status }}"))
+	add("dataguard", rule("OracleStandbyApplyLag", fmt.Sprintf(`oracledb_dataguard_lag_seconds{type="apply"} > %g or oracledb_dataguard_destination_lag_seconds{type="apply"} > %g`, rulesLag.Seconds(), rulesLag.Seconds()), "critical",
+		"Redo apply of {{ $labels.database }}{{ with $labels.db_unique_name }} on {{ . }}{{ end }} is {{ $value | humanizeDuration }} behind"))
+	add("dgbroker",
+		rule("OracleDgBrokerMemberError", `oracledb_dataguard_broker_member_status != 0`, "warning",
+			"Data Guard broker member {{ $labels.member }} of {{ $labels.database }} reports ORA-{{ $value }}"),
+		rule("OracleFsfoObserverMissing", `oracledb_dataguard_fsfo_observer_present == 0 and on(database, dbinstance) oracledb_dataguard_fsfo_status{status!="DISABLED"}`, "critical",
+			"Fast-start failover of {{ $labels.database }} is enabled without an observer"))
+	add("archivedest", rule("OracleArchiveDestError", `oracledb_archive_dest_status == 0`, "critical",
+		"Archive destination {{ $labels.dest_name }} of {{ $labels.database }} is {{ $labels.status }}"))
+	add("blocking", rule("OracleBlockingSessions", fmt.Sprintf(`oracledb_blocking_max_seconds > %g`, rulesBlock.Seconds()), "warning",
+		"A session of {{ $labels.database }}/{{ $labels.dbinstance }} is blocked for {{ $value | humanizeDuration }}"))
+	add("resource", rule("OracleResourceLimit", fmt.Sprintf(`oracledb_resource_limit{type="current"} / ignoring(type) oracledb_resource_limit{type="limit"} * 100 > %g`, *rulesResPct), "warning",
+		"{{ $labels.resource }} of {{ $labels.database }}/{{ $labels.dbinstance }} are at {{ $value | humanize }}% of their limit"))
+	add("awr", rule("OracleAwrSnapshotsStale", `oracledb_awr_last_snapshot_age_seconds > 2 * oracledb_awr_snapshot_interval_seconds`, "warning",
+		"The last AWR snapshot of {{ $labels.database }}/{{ $labels.dbinstance }} is {{ $value | humanizeDuration }} old"))
+	add("recovery", rule("OracleRecoveryAreaFull", fmt.Sprintf(`oracledb_recovery{type="percent_space_used"} - ignoring(type) oracledb_recovery{type="percent_space_reclaimable"} > %g`, *rulesFraPct), "critical",
+		"Recovery area of {{ $labels.database }} is {{ $value | humanize }}% used and not reclaimable"))
+
+	health, oem := false, false
+	for _, conn := range config.Cfgs {
+		health = health || conn.Health != ""
+		oem = oem || (conn.Oem != nil && conn.collectorEnabled("oem") && o.collects("oem"))
+	}
+	if health {
+		rules = append(rules, rule("OracleUnhealthy", `oracledb_healthy == 0`, "critical",
+			"The health expression of {{ $labels.database }}/{{ $labels.dbinstance }} is false"))
+	}
+	if oem {
+		rules = append(rules, rule("OracleOemTargetDown", `oracledb_oem_target_up == 0`, "critical",
+			"OEM reports {{ $labels.target }} ({{ $labels.target_type }}) as {{ $labels.status }}"))
+	}
+	return rules
+}
+
+// printRules writes the alerting rules file for the collectors enabled by o to w.
+func printRules(w io.Writer, o *scrapeOptions) error {
+	out, err := yaml.Marshal(struct {
+		Groups []ruleGroup `yaml:"groups"`
+	}{[]ruleGroup{{Name: "oracledb", Rules: alertRules(o)}}})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "# alerting rules for prometheus_oracle_exporter %s\n", Version)
+	_, err = w.Write(out)
+	return err
+}
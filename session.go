@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// oraDriver is the go-ora driver, its type is not exported.
+var oraDriver = func() driver.Driver {
+	db, _ := sql.Open("oracle", "")
+	return db.Driver()
+}()
+
+// nlsStatement returns the ALTER SESSION setting the -nls.* parameters, empty if none is set.
+func nlsStatement() string {
+	var set []string
+	for _, p := range []struct{ name, value string }{
+		{"NLS_NUMERIC_CHARACTERS", *nlsNumeric},
+		{"NLS_DATE_FORMAT", *nlsDate},
+	} {
+		if p.value != "" {
+			set = append(set, fmt.Sprintf("%s = '%s'", p.name, strings.Replace(p.value, "'", "''", -1)))
+		}
+	}
+	if len(set) == 0 {
+		return ""
+	}
+	return "ALTER SESSION SET " + strings.Join(set, " ")
+}
+
+// sessionInfo returns the PL/SQL block setting module and client identifier of a session
+// to the exporter and its version.
+func sessionInfo() string {
+	name := strings.Replace("prometheus_oracle_exporter "+Version, "'", "", -1)
+	return fmt.Sprintf("BEGIN DBMS_APPLICATION_INFO.SET_MODULE('%s', NULL); DBMS_SESSION.SET_IDENTIFIER('%s'); END;", name, name)
+}
+
+// sessionConnector opens go-ora connections and sets the NLS parameters and runs the
+// init_sql of the connection on each of them, the pool of database/sql opens new sessions
+// at any time. Custom queries formatting numbers or dates then return the same text
+// whatever the database defaults are.
+type sessionConnector struct {
+	dsn  string
+	init []string
+}
+
+func (c sessionConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := oraDriver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	// module and client identifier show the exporter sessions in v$session, e.g. for a
+	// resource manager consumer group mapping; DBMS_APPLICATION_INFO and DBMS_SESSION are
+	// granted to PUBLIC, failing to set them does not fail the connect
+	if err := execSession(conn, sessionInfo()); err != nil {
+		log.Debugln("set module:", err)
+	}
+	stmts := c.init
+	if stmt := nlsStatement(); stmt != "" {
+		stmts = append([]string{stmt}, stmts...)
+	}
+	for _, stmt := range stmts {
+		if err := execSession(conn, stmt); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s: %v", stmt, err)
+		}
+	}
+	return conn, nil
+}
+
+func (c sessionConnector) Driver() driver.Driver {
+	return oraDriver
+}
+
+// execSession runs stmt on a new driver connection.
+func execSession(conn driver.Conn, stmt string) error {
+	st, err := conn.Prepare(stmt)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	_, err = st.Exec(nil)
+	return err
+}
+
+// openDB returns the connection pool of dsn, initSQL is run on every new session.
+func openDB(dsn string, initSQL []string) *sql.DB {
+	return sql.OpenDB(sessionConnector{dsn, initSQL})
+}
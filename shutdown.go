@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// scrapes are the running collects, waited for on shutdown
+	scrapes    sync.WaitGroup
+	scrapesLok sync.Mutex
+	stopping   bool
+)
+
+// beginScrape registers a running collect, false once the exporter shuts down.
+func beginScrape() bool {
+	scrapesLok.Lock()
+	defer scrapesLok.Unlock()
+	if stopping {
+		return false
+	}
+	scrapes.Add(1)
+	return true
+}
+
+// shutdownOnSignal stops the exporter on SIGTERM or SIGINT: the listener of server (nil in
+// textfile mode) is closed, the running scrapes get up to -web.shutdown-timeout to finish
+// and the database connections are closed, so a rolling restart leaves no orphan sessions.
+// stopped is closed when done.
+func shutdownOnSignal(server *http.Server, stopped chan<- struct{}) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, os.Interrupt)
+	log.Infoln("Received", <-sig, "shutting down")
+	signal.Stop(sig)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownWait)
+	defer cancel()
+	if server != nil {
+		if err := server.Shutdown(ctx); err != nil {
+			log.Warnln("shutdown of the HTTP server:", err)
+		}
+	}
+
+	scrapesLok.Lock()
+	stopping = true
+	scrapesLok.Unlock()
+	done := make(chan struct{})
+	go func() {
+		scrapes.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Warnln("scrapes still running after", *shutdownWait)
+	}
+
+	closeConnections()
+	closeHeavy()
+	log.Infoln("Stopped")
+	close(stopped)
+}
+
+// closeConnections closes the connections of all targets, ending their sessions.
+func closeConnections() {
+	cfgLok.Lock()
+	defer cfgLok.Unlock()
+	for i := range config.Cfgs {
+		conf := &config.Cfgs[i]
+		if conf.db == nil {
+			continue
+		}
+		log.Infoln("close connect", redactConnection(conf.Connection))
+		if err := conf.db.Close(); err != nil {
+			log.Warnln("close connect", redactConnection(conf.Connection), err)
+		}
+		conf.db = nil
+	}
+}
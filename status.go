@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// collectorDuration are the duration percentiles of a collector of a target in /status.
+type collectorDuration struct {
+	Database  string  `json:"database"`
+	Instance  string  `json:"instance"`
+	Collector string  `json:"collector"`
+	Count     uint64  `json:"count"` // since the start of the exporter
+	P50       float64 `json:"p50"`
+	P95       float64 `json:"p95"`
+	P99       float64 `json:"p99"`
+}
+
+// collectorDurations returns the duration percentiles of every collector and target over
+// -collector.duration-window, the slowest first.
+func (e *Exporter) collectorDurations() []collectorDuration {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		e.durations.Collect(ch)
+		close(ch)
+	}()
+	durations := []collectorDuration{}
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil || pb.Summary == nil {
+			continue
+		}
+		var d collectorDuration
+		for _, l := range pb.Label {
+			switch l.GetName() {
+			case "database":
+				d.Database = l.GetValue()
+			case "dbinstance":
+				d.Instance = l.GetValue()
+			case "collector":
+				d.Collector = l.GetValue()
+			}
+		}
+		d.Count = pb.Summary.GetSampleCount()
+		for _, q := range pb.Summary.Quantile {
+			switch q.GetQuantile() {
+			case 0.5:
+				d.P50 = q.GetValue()
+			case 0.95:
+				d.P95 = q.GetValue()
+			case 0.99:
+				d.P99 = q.GetValue()
+			}
+		}
+		durations = append(durations, d)
+	}
+	sort.Slice(durations, func(i, j int) bool {
+		if durations[i].P99 != durations[j].P99 {
+			return durations[i].P99 > durations[j].P99
+		}
+		a, b := durations[i], durations[j]
+		return a.Database+"/"+a.Instance+"/"+a.Collector < b.Database+"/"+b.Instance+"/"+b.Collector
+	})
+	return durations
+}
+
+// StatusHandler shows the version of the exporter and the duration percentiles of the
+// collectors per target as JSON, for the capacity planning of the exporter itself.
+func (e *Exporter) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(struct {
+		Version            string              `json:"version"`
+		BuildDate          string              `json:"build_date,omitempty"`
+		DurationWindow     string              `json:"duration_window"`
+		CollectorDurations []collectorDuration `json:"collector_durations"`
+	}{Version, BuildDate, durWindow.String(), e.collectorDurations()})
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// targetStatus is the result of the last connect and scrape of a connection, for /targets.
+type targetStatus struct {
+	lastConnect   time.Time
+	connectError  string
+	lastScrape    time.Time
+	scrapeSeconds float64
+	scrapeErrors  []string // failed collectors of the last scrape
+	lastError     string
+	lastErrorTime time.Time
+}
+
+// statusLok guards the status of all connections.
+var statusLok sync.Mutex
+
+// setStatus changes the status of conf by f.
+func (conf *Config) setStatus(f func(s *targetStatus)) {
+	statusLok.Lock()
+	defer statusLok.Unlock()
+	if conf.status == nil {
+		conf.status = &targetStatus{}
+	}
+	f(conf.status)
+}
+
+// connected records the result of a connect of conf, err nil if it succeeded.
+func (conf *Config) connected(err error) {
+	conf.setStatus(func(s *targetStatus) {
+		s.lastConnect = time.Now()
+		s.connectError = ""
+		if err != nil {
+			s.connectError = err.Error()
+			s.lastError, s.lastErrorTime = s.connectError, s.lastConnect
+		}
+	})
+}
+
+// scrapeFailed records the error of a collector in the running scrape of conf.
+func (conf *Config) scrapeFailed(collector string, err error) {
+	conf.setStatus(func(s *targetStatus) {
+		msg := collector + ": " + err.Error()
+		s.scrapeErrors = append(s.scrapeErrors, msg)
+		s.lastError, s.lastErrorTime = msg, time.Now()
+	})
+}
+
+// targetInfo is a connection in /targets.
+type targetInfo struct {
+	Database      string     `json:"database"`
+	Instance      string     `json:"instance"`
+	Connection    string     `json:"connection"`
+	Host          string     `json:"host,omitempty"`
+	Version       string     `json:"version,omitempty"`
+	Up            bool       `json:"up"`
+	LastConnect   *time.Time `json:"last_connect,omitempty"`
+	ConnectError  string     `json:"connect_error,omitempty"`
+	LastScrape    *time.Time `json:"last_scrape,omitempty"`
+	ScrapeSeconds float64    `json:"scrape_seconds"`
+	ScrapeErrors  []string   `json:"scrape_errors,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorTime *time.Time `json:"last_error_time,omitempty"`
+}
+
+// Failing reports whether the target is down or its last scrape had errors.
+func (t targetInfo) Failing() bool {
+	return !t.Up || len(t.ScrapeErrors) > 0
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// targetInfos returns the status of all connections, the failing ones first.
+func targetInfos() []targetInfo {
+	cfgLok.Lock()
+	statusLok.Lock()
+	var infos []targetInfo
+	for _, conf := range config.Cfgs {
+		t := targetInfo{
+			Database:   conf.Database,
+			Instance:   conf.Instance,
+			Connection: redactConnection(conf.Connection),
+			Host:       conf.hostname,
+			Version:    conf.version,
+			Up:         conf.db != nil,
+		}
+		if s := conf.status; s != nil {
+			t.LastConnect = timePtr(s.lastConnect)
+			t.ConnectError = s.connectError
+			t.LastScrape = timePtr(s.lastScrape)
+			t.ScrapeSeconds = s.scrapeSeconds
+			t.ScrapeErrors = append([]string(nil), s.scrapeErrors...)
+			t.LastError = s.lastError
+			t.LastErrorTime = timePtr(s.lastErrorTime)
+		}
+		infos = append(infos, t)
+	}
+	statusLok.Unlock()
+	cfgLok.Unlock()
+
+	sort.SliceStable(infos, func(i, j int) bool {
+		if infos[i].Failing() != infos[j].Failing() {
+			return infos[i].Failing()
+		}
+		return infos[i].Database+"/"+infos[i].Instance < infos[j].Database+"/"+infos[j].Instance
+	})
+	return infos
+}
+
+// pageFuncs are the functions of the HTML pages.
+var pageFuncs = template.FuncMap{
+	"time": func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format("2006-01-02 15:04:05")
+	},
+}
+
+var targetsPage = template.Must(template.New("targets").Funcs(pageFuncs).Parse(`<html>
+<head><title>Targets - Prometheus Oracle exporter</title>
+<style>td, th { padding: 2px 8px; text-align: left; } tr.failing { background: #fdd; }</style></head>
+<body>
+<h1>Targets</h1>
+<table>
+<tr><th>Database</th><th>Instance</th><th>Connection</th><th>Host</th><th>Version</th><th>Up</th><th>Last connect</th><th>Last scrape</th><th>Seconds</th><th>Errors of the last scrape</th><th>Last error</th></tr>
+{{range .}}<tr{{if .Failing}} class="failing"{{end}}><td>{{.Database}}</td><td>{{.Instance}}</td><td>{{.Connection}}</td><td>{{.Host}}</td><td>{{.Version}}</td><td>{{.Up}}</td>
+<td>{{time .LastConnect}} {{.ConnectError}}</td><td>{{time .LastScrape}}</td><td>{{printf "%.3f" .ScrapeSeconds}}</td>
+<td>{{range .ScrapeErrors}}{{.}}<br>{{end}}</td><td>{{time .LastErrorTime}} {{.LastError}}</td></tr>
+{{end}}</table>
+</body>
+</html>`))
+
+// TargetsHandler lists every connection with its resolved names, the result of the last
+// connect and scrape and the last error, as HTML for browsers (or format=html) and as
+// JSON otherwise (or format=json).
+func TargetsHandler(w http.ResponseWriter, r *http.Request) {
+	infos := targetInfos()
+	format := r.URL.Query().Get("format")
+	if format == "" && strings.Contains(r.Header.Get("Accept"), "text/html") {
+		format = "html"
+	}
+	if format == "html" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		targetsPage.Execute(w, infos)
+		return
+	}
+	if infos == nil {
+		infos = []targetInfo{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(infos)
+}
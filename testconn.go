@@ -2,12 +2,11 @@ package main
 
 import (
 	"context"
-	"database/sql"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
-	"strconv"
-	"strings"
 	"sync"
 	"time"
 
@@ -30,79 +29,102 @@ func (e *Exporter) execConn(testStepAll chan int) {
 	if err != nil {
 		return
 	}
-	cmd := exec.Command(pg, "-testconn")
-	cc := strings.Builder{}
-	cmd.Stderr = &cc
-	cmd.Run()
-	strs := strings.Split(cc.String(), "\n")
-	for _, v := range strs {
-		if strings.Contains(v, "query time") {
-			fs := strings.Split(v, " ")
-			if len(fs) == 4 {
-				connstr := fs[2]
-				ipport, svname := splitConnStr(connstr)
-				ts := fs[3]
-				if strings.HasSuffix(ts, "ms") {
-					ts = strings.Replace(ts, "ms", "", 1)
-					dr, err := strconv.ParseFloat(ts, 64)
-					if err != nil {
-						e.used_times.WithLabelValues(ipport, svname).Set(999)
-						continue
-					}
-					e.used_times.WithLabelValues(ipport, svname, "connectsucc").Set(dr / 1000)
-				} else {
-					ts = strings.Replace(ts, "s", "", 1)
-					dr, err := strconv.ParseFloat(ts, 64)
-					if err != nil {
-						e.used_times.WithLabelValues(ipport, svname).Set(999)
-						continue
-					}
-					e.used_times.WithLabelValues(ipport, svname, "connectsucc").Set(dr)
-				}
-			}
+	// same flags, so the child reads the same config
+	cmd := exec.Command(pg, append([]string{"testconn", "--format=json"}, os.Args[1:]...)...)
+	out, _ := cmd.Output()
+	var results []connTest
+	if err := json.Unmarshal(out, &results); err != nil {
+		log.Warnln(" testconn ", err)
+		return
+	}
+	for _, r := range results {
+		if r.Ok {
+			e.used_times.WithLabelValues(r.Ipport, r.Svname, "connectsucc").Set(r.Connect + r.Query)
+		} else {
+			e.used_times.WithLabelValues(r.Ipport, r.Svname, "connectsucc").Set(999)
 		}
 	}
 }
 
-func testConnects() {
+// connTest is the result of testconn for one connection.
+type connTest struct {
+	Database string `json:"database"`
+	Instance string `json:"instance"`
+	Ipport   string `json:"ipport"`
+	Svname   string `json:"svname"`
+	Ok       bool   `json:"ok"`
+	// Connect is the time of open and ping, Query of the identity query
+	Connect  float64 `json:"connect_seconds"`
+	Query    float64 `json:"query_seconds"`
+	DbName   string  `json:"db_name,omitempty"`
+	InstName string  `json:"instance_name,omitempty"`
+	Hostname string  `json:"host_name,omitempty"`
+	// Step is dsn, ping or query if the test failed
+	Step  string `json:"failed_step,omitempty"`
+	Error string `json:"error,omitempty"`
+}
 
-	for _, v := range config.Cfgs {
+// testConnects tests all connections in parallel and writes the results to w as JSON or
+// text, one line per connection. It returns false if a connection failed.
+func testConnects(w io.Writer, format string) bool {
+	results := make([]connTest, len(config.Cfgs))
+	for i := range config.Cfgs {
 		testconnwg.Add(1)
-		go testConn(v.Connection)
+		go testConn(&config.Cfgs[i], &results[i])
 	}
-
 	testconnwg.Wait()
+
+	ok := true
+	for _, r := range results {
+		ok = ok && r.Ok
+	}
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(results)
+		return ok
+	}
+	for _, r := range results {
+		if r.Ok {
+			fmt.Fprintf(w, "ok %s/%s %s/%s connect %.3fs query %.3fs\n", r.Database, r.Instance, r.Ipport, r.Svname, r.Connect, r.Query)
+		} else {
+			fmt.Fprintf(w, "failed %s/%s %s/%s %s: %s\n", r.Database, r.Instance, r.Ipport, r.Svname, r.Step, r.Error)
+		}
+	}
+	return ok
 }
 
-func testConn(str string) {
+func testConn(conf *Config, r *connTest) {
 	defer testconnwg.Done()
-	t0 := time.Now()
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(*timeout)*time.Second)
-	defer cancel()
-
-	db, err := sql.Open("oracle", str)
+	r.Database, r.Instance = conf.Database, conf.Instance
+	r.Ipport, r.Svname = splitConnStr(conf.Connection)
+	fail := func(step string, err error) {
+		r.Step, r.Error = step, err.Error()
+	}
+	dsn, err := conf.dsn()
 	if err != nil {
-		log.Infoln(" open ", str, "  err ", err)
+		fail("dsn", err)
 		return
 	}
-	defer func() {
-		err = db.Close()
-	}()
+	t0 := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(*timeout)*time.Second)
+	defer cancel()
 
+	db := openDB(dsn, conf.InitSQL)
+	defer db.Close()
 	err = db.PingContext(ctx)
+	r.Connect = time.Since(t0).Seconds()
 	if err != nil {
-		log.Infoln(" ping ", str, "  err ", err)
+		fail("ping", err)
 		return
 	}
 
-	log.Infoln(" ping time  ", str, time.Since(t0))
-
-	var dbname, inname, hostname string
-	err = db.QueryRowContext(ctx, "select name, instance_name, host_name from v$database, v$instance").Scan(&dbname, &inname, &hostname)
+	t1 := time.Now()
+	err = db.QueryRowContext(ctx, "select name, instance_name, host_name from v$database, v$instance").Scan(&r.DbName, &r.InstName, &r.Hostname)
+	r.Query = time.Since(t1).Seconds()
 	if err != nil {
-		log.Infoln(" select err ", err)
+		fail("query", err)
 		return
 	}
-	os.Stderr.WriteString(fmt.Sprintf("query time %s %v\n", str, time.Since(t0)))
-
+	r.Ok = true
 }
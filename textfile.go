@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	log "github.com/sirupsen/logrus"
+)
+
+// writeMetrics gathers all metrics from g and writes them in the text exposition format.
+func writeMetrics(w io.Writer, g prometheus.Gatherer) error {
+	mfs, err := g.Gather()
+	if err != nil {
+		// Gather returns everything it could collect together with the error
+		log.Warnln("textfile gather:", err)
+	}
+	for _, mf := range mfs {
+		if _, err := expfmt.MetricFamilyToText(w, mf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTextfile writes the metrics to path, or to stdout if path is "-".
+// The file is written to a temporary file first and renamed, so the node_exporter
+// textfile collector never picks up a partially written file.
+func writeTextfile(path string, g prometheus.Gatherer) error {
+	if path == "-" {
+		return writeMetrics(os.Stdout, g)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = writeMetrics(tmp, g)
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// runTextfile scrapes the exporter every -textfile.interval and writes the result,
+// used instead of the HTTP listener where scraping the DB hosts is not allowed.
+func runTextfile(e *Exporter) {
+	// a dedicated registry, the go_* and process_* metrics of the default one
+	// would collide with node_exporter's own metrics
+	reg := prometheus.NewRegistry()
+	prometheus.WrapRegistererWith(constLabels, reg).MustRegister(e)
+
+	log.Infoln("Writing metrics to", *textFile, "every", *textInterval)
+
+	for {
+		t0 := time.Now()
+		if err := writeTextfile(*textFile, targetLabels(reg)); err != nil {
+			log.Errorln("write textfile:", err)
+		}
+		time.Sleep(*textInterval - time.Since(t0))
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Ldap is the directory (OID, AD) resolving TNS aliases by Oracle LDAP naming.
+type Ldap struct {
+	Server       string `yaml:"server"`
+	Base         string `yaml:"base"`
+	BindDN       string `yaml:"bind_dn,omitempty"`
+	BindPassword string `yaml:"bind_password,omitempty" json:"-"`
+}
+
+var tnsParamRe = regexp.MustCompile(`(?i)\(\s*(HOST|PORT|SERVICE_NAME|SID)\s*=\s*([^)\s]+)\s*\)`)
+
+// tnsnamesFile returns the path of tnsnames.ora, in -tnsadmin or else $ORACLE_HOME/network/admin.
+func tnsnamesFile() string {
+	dir := *tnsAdmin
+	if dir == "" && os.Getenv("ORACLE_HOME") != "" {
+		dir = filepath.Join(os.Getenv("ORACLE_HOME"), "network", "admin")
+	}
+	return filepath.Join(dir, "tnsnames.ora")
+}
+
+// parseTnsnames returns the connect descriptors of a tnsnames.ora by upper case alias.
+func parseTnsnames(content string) map[string]string {
+	var b strings.Builder
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	content = b.String()
+
+	entries := make(map[string]string)
+	for len(content) > 0 {
+		eq := strings.Index(content, "=")
+		open := strings.Index(content, "(")
+		if eq < 0 || open < eq {
+			break
+		}
+		// an entry may have several aliases: a, b = (DESCRIPTION=...)
+		aliases := strings.Split(content[:eq], ",")
+		depth, end := 0, -1
+		for i := open; i < len(content); i++ {
+			switch content[i] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+			if depth == 0 {
+				end = i + 1
+				break
+			}
+		}
+		if end < 0 {
+			break
+		}
+		for _, alias := range aliases {
+			entries[strings.ToUpper(strings.TrimSpace(alias))] = content[open:end]
+		}
+		content = content[end:]
+	}
+	return entries
+}
+
+// tnsDescriptor returns the connect descriptor of alias in tnsnames.ora.
+func tnsDescriptor(alias string) (string, error) {
+	file := tnsnamesFile()
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %v", alias, err)
+	}
+	desc, ok := parseTnsnames(string(content))[strings.ToUpper(alias)]
+	if !ok {
+		return "", fmt.Errorf("alias %s not found in %s", alias, file)
+	}
+	return desc, nil
+}
+
+// ldapFilterEscaper escapes the special characters of a value in an LDAP search filter (RFC 4515).
+var ldapFilterEscaper = strings.NewReplacer(`\`, `\5c`, `*`, `\2a`, `(`, `\28`, `)`, `\29`, "\x00", `\00`)
+
+// descriptor returns the connect descriptor of alias, the orclNetDescString of the entry
+// cn=<alias>,cn=OracleContext,<base>. It runs ldapsearch of the OpenLDAP clients by command,
+// which kills it after commandTimeout.
+func (l *Ldap) descriptor(alias string) (string, error) {
+	args := []string{"-x", "-LLL", "-o", "ldif-wrap=no", "-H", l.Server,
+		"-b", "cn=OracleContext," + l.Base, "(cn=" + ldapFilterEscaper.Replace(alias) + ")", "orclNetDescString"}
+	if l.BindDN != "" {
+		// password by file, not visible in the process list
+		pw, err := ioutil.TempFile("", "ldappw")
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(pw.Name())
+		pw.WriteString(l.BindPassword)
+		pw.Close()
+		args = append(args, "-D", l.BindDN, "-y", pw.Name())
+	}
+	out, err := command("ldapsearch", args...)
+	if err != nil {
+		return "", err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		i := strings.Index(line, ":")
+		if i < 0 || !strings.EqualFold(line[:i], "orclNetDescString") {
+			continue
+		}
+		value := line[i+1:]
+		if strings.HasPrefix(value, ":") {
+			// base64 encoded value
+			b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value[1:]))
+			if err != nil {
+				return "", err
+			}
+			return string(b), nil
+		}
+		return strings.TrimSpace(value), nil
+	}
+	return "", fmt.Errorf("alias %s not found in %s", alias, l.Server)
+}
+
+// connAddress is the first address and the service or SID of a connect descriptor or
+// easy connect string.
+type connAddress struct {
+	host, port, service, sid string
+}
+
+// hostPort returns host:port, IPv6 literals in brackets, the port 1521 if none is given.
+func (a connAddress) hostPort() string {
+	port := a.port
+	if port == "" {
+		port = "1521"
+	}
+	return net.JoinHostPort(strings.Trim(a.host, "[]"), port)
+}
+
+// parseDescriptor returns the first address of a connect descriptor, e.g. of a SCAN or
+// an ADDRESS_LIST with several hosts, and its SERVICE_NAME or SID.
+func parseDescriptor(desc string) connAddress {
+	params := make(map[string]string)
+	for _, m := range tnsParamRe.FindAllStringSubmatch(desc, -1) {
+		// the first address of an address list is used
+		if _, ok := params[strings.ToUpper(m[1])]; !ok {
+			params[strings.ToUpper(m[1])] = m[2]
+		}
+	}
+	return connAddress{host: params["HOST"], port: params["PORT"], service: params["SERVICE_NAME"], sid: params["SID"]}
+}
+
+// parseEasyConnect returns the first address and the service of an easy connect string
+// [//]host[:port][/service[:server][/instance]]. The host may be an IPv6 literal in
+// brackets or a list of hosts separated by commas sharing the last port, e.g.
+// scan1,scan2:1521/APP.
+func parseEasyConnect(s string) connAddress {
+	s = strings.TrimPrefix(s, "//")
+	if i := strings.Index(s, "?"); i >= 0 {
+		s = s[:i]
+	}
+	hosts, service := s, ""
+	if i := strings.Index(s, "/"); i >= 0 {
+		hosts, service = s[:i], s[i+1:]
+	}
+	if i := strings.IndexAny(service, ":/"); i >= 0 {
+		// :server type or /instance name
+		service = service[:i]
+	}
+	list := strings.Split(hosts, ",")
+	host, port := splitHostPort(list[0])
+	if port == "" {
+		_, port = splitHostPort(list[len(list)-1])
+	}
+	return connAddress{host: host, port: port, service: service}
+}
+
+// splitHostPort splits host[:port] or [ipv6][:port].
+func splitHostPort(s string) (host, port string) {
+	if strings.HasPrefix(s, "[") {
+		if i := strings.Index(s, "]"); i >= 0 {
+			return s[1:i], strings.TrimPrefix(s[i+1:], ":")
+		}
+	}
+	if strings.Count(s, ":") > 1 {
+		// IPv6 literal without brackets and port
+		return s, ""
+	}
+	if i := strings.Index(s, ":"); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// tnsURL turns a connection of the form [user/password@]alias, resolved by tnsnames.ora or
+// else by LDAP naming, [user/password@]host[:port]/service (easy connect) or
+// [user/password@](DESCRIPTION=...) into a go-ora URL.
+func tnsURL(conn string, ldap *Ldap) (string, error) {
+	var user *url.Userinfo
+	target := conn
+	if i := strings.LastIndex(conn, "@"); i >= 0 {
+		target = conn[i+1:]
+		if up := strings.SplitN(conn[:i], "/", 2); len(up) == 2 {
+			user = url.UserPassword(up[0], up[1])
+		} else {
+			user = url.User(up[0])
+		}
+	}
+
+	u := &url.URL{Scheme: "oracle", User: user}
+	if strings.Contains(target, "/") && !strings.HasPrefix(target, "(") {
+		// easy connect
+		a := parseEasyConnect(target)
+		u.Host, u.Path = a.hostPort(), "/"+a.service
+		return u.String(), nil
+	}
+
+	desc := target
+	if !strings.HasPrefix(target, "(") {
+		var err error
+		desc, err = tnsDescriptor(target)
+		if err != nil && ldap != nil {
+			desc, err = ldap.descriptor(target)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	a := parseDescriptor(desc)
+	if a.host == "" {
+		return "", fmt.Errorf("alias %s: no HOST in %s", target, desc)
+	}
+	u.Host = a.hostPort()
+	switch {
+	case a.service != "":
+		u.Path = "/" + a.service
+	case a.sid != "":
+		u.RawQuery = url.Values{"SID": {a.sid}}.Encode()
+	default:
+		return "", fmt.Errorf("alias %s: no SERVICE_NAME or SID in %s", target, desc)
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestParseTnsnames(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+	}{
+		{"empty", "", map[string]string{}},
+		{"single", "develop = (DESCRIPTION=(ADDRESS=(HOST=db1)(PORT=1521))(CONNECT_DATA=(SERVICE_NAME=DEV)))",
+			map[string]string{"DEVELOP": "(DESCRIPTION=(ADDRESS=(HOST=db1)(PORT=1521))(CONNECT_DATA=(SERVICE_NAME=DEV)))"}},
+		{"multi line with comment", "# test databases\nTEST =\n  (DESCRIPTION =\n    (ADDRESS = (HOST = db2)) # primary\n  )\n",
+			map[string]string{"TEST": "(DESCRIPTION =\n    (ADDRESS = (HOST = db2)) \n  )"}},
+		{"several aliases", "a, b = (DESCRIPTION=(ADDRESS=(HOST=db3)))",
+			map[string]string{"A": "(DESCRIPTION=(ADDRESS=(HOST=db3)))", "B": "(DESCRIPTION=(ADDRESS=(HOST=db3)))"}},
+		{"several entries", "one=(DESCRIPTION=(SID=ONE))\ntwo=(DESCRIPTION=(SID=TWO))",
+			map[string]string{"ONE": "(DESCRIPTION=(SID=ONE))", "TWO": "(DESCRIPTION=(SID=TWO))"}},
+		{"unbalanced", "bad = (DESCRIPTION=(HOST=db4)", map[string]string{}},
+	}
+	for _, tt := range tests {
+		got := parseTnsnames(tt.content)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %d entries %q, want %d", tt.name, len(got), got, len(tt.want))
+			continue
+		}
+		for alias, desc := range tt.want {
+			if got[alias] != desc {
+				t.Errorf("%s: %s = %q, want %q", tt.name, alias, got[alias], desc)
+			}
+		}
+	}
+}
+
+func TestLdapFilterEscaper(t *testing.T) {
+	tests := []struct {
+		alias string
+		want  string
+	}{
+		{"develop", "develop"},
+		{"*", `\2a`},
+		{"a)(cn=*", `a\29\28cn=\2a`},
+		{`back\slash`, `back\5cslash`},
+		{"nul\x00", `nul\00`},
+	}
+	for _, tt := range tests {
+		if got := ldapFilterEscaper.Replace(tt.alias); got != tt.want {
+			t.Errorf("escape %q = %q, want %q", tt.alias, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v2"
+)
+
+// webConfig is the -web.config.file, the tls_server_config, http_server_config and
+// basic_auth_users of the Prometheus exporter-toolkit web configuration file.
+type webConfig struct {
+	TLSConfig  tlsServerConfig  `yaml:"tls_server_config"`
+	HTTPConfig httpServerConfig `yaml:"http_server_config"`
+	// Users maps user names to bcrypt hashes of their passwords
+	Users map[string]string `yaml:"basic_auth_users"`
+}
+
+type httpServerConfig struct {
+	// HTTP2 is nil if not set, HTTP/2 is enabled by default like in the toolkit
+	HTTP2   *bool             `yaml:"http2"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+// responseHeaders are the headers accepted by http_server_config, as in the toolkit.
+var responseHeaders = map[string]bool{
+	"Content-Security-Policy":   true,
+	"Strict-Transport-Security": true,
+	"X-Content-Type-Options":    true,
+	"X-Frame-Options":           true,
+	"X-XSS-Protection":          true,
+}
+
+type tlsServerConfig struct {
+	CertFile                 string   `yaml:"cert_file"`
+	KeyFile                  string   `yaml:"key_file"`
+	ClientAuth               string   `yaml:"client_auth_type"`
+	ClientCAs                string   `yaml:"client_ca_file"`
+	ClientAllowedSans        []string `yaml:"client_allowed_sans"`
+	MinVersion               string   `yaml:"min_version"`
+	MaxVersion               string   `yaml:"max_version"`
+	CipherSuites             []string `yaml:"cipher_suites"`
+	CurvePreferences         []string `yaml:"curve_preferences"`
+	PreferServerCipherSuites bool     `yaml:"prefer_server_cipher_suites"`
+}
+
+var curves = map[string]tls.CurveID{
+	"CurveP256": tls.CurveP256,
+	"CurveP384": tls.CurveP384,
+	"CurveP521": tls.CurveP521,
+	"X25519":    tls.X25519,
+}
+
+var tlsVersions = map[string]uint16{
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": tls.VersionTLS13,
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"":                           tls.NoClientCert,
+	"NoClientCert":               tls.NoClientCert,
+	"RequestClientCert":          tls.RequestClientCert,
+	"RequireAnyClientCert":       tls.RequireAnyClientCert,
+	"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+	"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+}
+
+// cipherSuite returns the ID of a cipher suite by its Go name, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256.
+func cipherSuite(name string) (uint16, bool) {
+	for _, cs := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if cs.Name == name {
+			return cs.ID, true
+		}
+	}
+	return 0, false
+}
+
+// readWebConfig reads the web config file at path.
+func readWebConfig(path string) (*webConfig, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var wc webConfig
+	if err := yaml.UnmarshalStrict(content, &wc); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for user, hash := range wc.Users {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("%s: basic_auth_users %s: %v", path, user, err)
+		}
+	}
+	for name := range wc.HTTPConfig.Headers {
+		if !responseHeaders[http.CanonicalHeaderKey(name)] {
+			return nil, fmt.Errorf("%s: http_server_config: header %s is not allowed", path, name)
+		}
+	}
+	return &wc, nil
+}
+
+// tlsConfig returns the TLS settings of the web config read from path, nil if it has none.
+// The certificate is read again on every handshake, so a renewed certificate is used
+// without restart.
+func (wc *webConfig) tlsConfig(path string) (*tls.Config, error) {
+	c := wc.TLSConfig
+	if c.CertFile == "" && c.KeyFile == "" {
+		return nil, nil
+	}
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, fmt.Errorf("%s: tls_server_config needs cert_file and key_file", path)
+	}
+	if _, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile); err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		MinVersion:               tls.VersionTLS12,
+		PreferServerCipherSuites: c.PreferServerCipherSuites,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+			return &cert, err
+		},
+	}
+	var ok bool
+	if c.MinVersion != "" {
+		if cfg.MinVersion, ok = tlsVersions[c.MinVersion]; !ok {
+			return nil, fmt.Errorf("%s: unknown min_version %s", path, c.MinVersion)
+		}
+	}
+	if c.MaxVersion != "" {
+		if cfg.MaxVersion, ok = tlsVersions[c.MaxVersion]; !ok {
+			return nil, fmt.Errorf("%s: unknown max_version %s", path, c.MaxVersion)
+		}
+	}
+	for _, name := range c.CipherSuites {
+		id, ok := cipherSuite(name)
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown cipher suite %s", path, name)
+		}
+		cfg.CipherSuites = append(cfg.CipherSuites, id)
+	}
+	for _, name := range c.CurvePreferences {
+		id, ok := curves[name]
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown curve %s", path, name)
+		}
+		cfg.CurvePreferences = append(cfg.CurvePreferences, id)
+	}
+	if cfg.ClientAuth, ok = clientAuthTypes[c.ClientAuth]; !ok {
+		return nil, fmt.Errorf("%s: unknown client_auth_type %s", path, c.ClientAuth)
+	}
+	if c.ClientCAs != "" {
+		pem, err := ioutil.ReadFile(c.ClientCAs)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientCAs = x509.NewCertPool()
+		if !cfg.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates in %s", path, c.ClientCAs)
+		}
+	} else if cfg.ClientAuth == tls.VerifyClientCertIfGiven || cfg.ClientAuth == tls.RequireAndVerifyClientCert {
+		return nil, fmt.Errorf("%s: client_auth_type %s needs client_ca_file", path, c.ClientAuth)
+	}
+	if len(c.ClientAllowedSans) > 0 {
+		if cfg.ClientAuth != tls.RequireAndVerifyClientCert && cfg.ClientAuth != tls.VerifyClientCertIfGiven {
+			return nil, fmt.Errorf("%s: client_allowed_sans needs client_auth_type RequireAndVerifyClientCert", path)
+		}
+		cfg.VerifyPeerCertificate = allowedSans(c.ClientAllowedSans)
+	}
+	return cfg, nil
+}
+
+// allowedSans returns a check of verified client certificates accepting only those with
+// one of the subject alternative names sans, e.g. the DNS names of the Prometheus servers.
+func allowedSans(sans []string) func([][]byte, [][]*x509.Certificate) error {
+	allowed := make(map[string]bool)
+	for _, san := range sans {
+		allowed[san] = true
+	}
+	return func(_ [][]byte, chains [][]*x509.Certificate) error {
+		if len(chains) == 0 {
+			// VerifyClientCertIfGiven without a certificate
+			return nil
+		}
+		cert := chains[0][0]
+		names := append([]string(nil), cert.DNSNames...)
+		names = append(names, cert.EmailAddresses...)
+		for _, ip := range cert.IPAddresses {
+			names = append(names, ip.String())
+		}
+		for _, uri := range cert.URIs {
+			names = append(names, uri.String())
+		}
+		for _, name := range names {
+			if allowed[name] {
+				return nil
+			}
+		}
+		return fmt.Errorf("client certificate %s: no allowed subject alternative name in %v", cert.Subject, names)
+	}
+}
+
+// basicAuthEnabled is set by listen if every request is authenticated by basic_auth_users.
+var basicAuthEnabled bool
+
+// basicAuth is the basic_auth_users of the web config, checked passwords are cached
+// because bcrypt is deliberately slow.
+type basicAuth struct {
+	users   map[string]string
+	handler http.Handler
+	// dummy is compared for unknown users, so their requests take as long as the others
+	dummy   []byte
+	lok     sync.Mutex
+	checked map[[sha256.Size]byte]bool
+}
+
+func (a *basicAuth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, password, ok := r.BasicAuth()
+	if ok && a.valid(user, password) {
+		a.handler.ServeHTTP(w, r)
+		return
+	}
+	w.Header().Set("WWW-Authenticate", `Basic realm="prometheus_oracle_exporter"`)
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+func (a *basicAuth) valid(user, password string) bool {
+	hash, known := a.users[user]
+	if !known {
+		hash = string(a.dummy)
+	}
+	key := sha256.Sum256([]byte(user + "\x00" + password + "\x00" + hash))
+	a.lok.Lock()
+	cached := a.checked[key]
+	a.lok.Unlock()
+	if cached {
+		return known
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil || !known {
+		return false
+	}
+	a.lok.Lock()
+	a.checked[key] = true
+	a.lok.Unlock()
+	return true
+}
+
+// withHeaders returns handler setting the http_server_config headers on every response,
+// the 401 of basic auth included.
+func withHeaders(headers map[string]string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, value := range headers {
+			w.Header().Set(name, value)
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// listen serves server on its address, with HTTPS and basic auth as configured by
+// -web.config.file. It returns http.ErrServerClosed after a shutdown.
+func listen(server *http.Server) error {
+	if *webConfigFile == "" {
+		return server.ListenAndServe()
+	}
+	wc, err := readWebConfig(*webConfigFile)
+	if err != nil {
+		return err
+	}
+	cfg, err := wc.tlsConfig(*webConfigFile)
+	if err != nil {
+		return err
+	}
+	if len(wc.Users) > 0 {
+		log.Infoln("basic auth enabled by", *webConfigFile)
+		dummy, err := bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		server.Handler = &basicAuth{users: wc.Users, handler: server.Handler, dummy: dummy, checked: make(map[[sha256.Size]byte]bool)}
+		basicAuthEnabled = true
+	}
+	if len(wc.HTTPConfig.Headers) > 0 {
+		server.Handler = withHeaders(wc.HTTPConfig.Headers, server.Handler)
+	}
+	server.TLSConfig = cfg
+	if cfg == nil {
+		return server.ListenAndServe()
+	}
+	if wc.HTTPConfig.HTTP2 != nil && !*wc.HTTPConfig.HTTP2 {
+		// a non-nil empty map disables HTTP/2 of ListenAndServeTLS
+		server.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	}
+	log.Infoln("TLS enabled by", *webConfigFile)
+	return server.ListenAndServeTLS("", "")
+}
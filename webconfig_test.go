@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadWebConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tests := []struct {
+		content string
+		wantErr string
+	}{
+		{"tls_server_config:\n  cert_file: a.crt\n  key_file: a.key\n  curve_preferences: [X25519]\n", ""},
+		{"http_server_config:\n  http2: false\n  headers:\n    X-Frame-Options: deny\n", ""},
+		{"http_server_config:\n  headers:\n    Server: oracle\n", "header Server is not allowed"},
+		{"http_server_config:\n  compression: true\n", "not found"},
+		{"basic_auth_users:\n  prometheus: secret\n", "basic_auth_users prometheus"},
+	}
+	for i, tt := range tests {
+		path := filepath.Join(dir, "web.yml")
+		if err := ioutil.WriteFile(path, []byte(tt.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := readWebConfig(path)
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%d: readWebConfig: %v", i, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%d: readWebConfig: %v, want an error containing %q", i, err, tt.wantErr)
+		}
+	}
+}